@@ -4,25 +4,55 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
+	"test-task/internal/auth"
 	"test-task/internal/cache"
 	"test-task/internal/config"
 	"test-task/internal/db"
+	"test-task/internal/decoder"
+	"test-task/internal/encoder"
+	"test-task/internal/export"
+	"test-task/internal/feed"
+	"test-task/internal/graphqlapi"
+	"test-task/internal/grpcserver"
 	"test-task/internal/handlers"
 	"test-task/internal/kafka"
+	"test-task/internal/kafkaconn"
+	"test-task/internal/masking"
+	"test-task/internal/metrics"
+	"test-task/internal/outbox"
+	"test-task/internal/ratelimit"
 	"test-task/internal/repository"
+	"test-task/internal/schemaregistry"
 	"test-task/internal/service"
+	"test-task/internal/tracing"
+	"test-task/internal/webhook"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 func main() {
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Fatalf("Error init tracing: %v", err)
+	}
+	defer func() {
+		tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer tracingCancel()
+		if err := shutdownTracing(tracingCtx); err != nil {
+			log.Printf("Error shutdown tracing: %v", err)
+		}
+	}()
+
 	database := db.InitDB()
 	defer func() {
 		if err := database.Close(); err != nil {
@@ -31,36 +61,197 @@ func main() {
 	}()
 
 	orderCache := cache.InitCache(database)
-	orderRepo := repository.NewOrderRepository(database)
-	orderService := service.NewOrderService(orderCache, orderRepo)
+	dbBreaker := db.NewBreaker(database)
+	defer dbBreaker.Close()
+	orderRepo := repository.NewOrderRepository(database, dbBreaker)
+	rules, err := service.NewRuleEngineFromConfig()
+	if err != nil {
+		log.Fatalf("Error init business rules: %v", err)
+	}
+	orderService := service.NewOrderService(orderCache, orderRepo, rules)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	kafkaSubscriber := kafka.NewKafkaSubscriber(orderService)
+	masker, err := masking.New(config.MaskingRules())
+	if err != nil {
+		log.Fatalf("Error init masking: %v", err)
+	}
+	feedConf := config.FeedConfig()
+	broadcaster := feed.NewBroadcaster(feedConf.BufferSize, feedConf.ClientBuffer)
+	orderService.AddObserver(broadcaster)
+	webhookRepo := repository.NewWebhookRepository(database)
+	if config.WebhooksEnabled() {
+		orderRepo.AddTxHook(webhook.NewDispatcher(webhookRepo, masker))
+		go webhook.NewSender(webhookRepo).Run(ctx)
+	}
+
+	kafkaConn, err := kafkaconn.FromConfig()
+	if err != nil {
+		log.Fatalf("Error init kafka connection: %v", err)
+	}
+	dlq := kafka.NewDeadLetterWriter(kafkaConn)
+	defer dlq.Close()
+	decoders := decoder.Default()
+	if url := config.SchemaRegistryURL(); url != "" {
+		registry := schemaregistry.NewClient(url, config.SchemaRegistryUsername(), config.SchemaRegistryPassword())
+		decoders.Register(decoder.FormatAvro, decoder.CurrentSchemaVersion, decoder.NewAvroDecoder(registry).Decode)
+		log.Println("Avro decoding enabled with schema registry", url)
+	}
+	kafkaSubscriber := kafka.NewKafkaSubscriber(kafkaConn, dbBreaker)
+	kafkaSubscriber.Handle("orders", kafka.NewOrderHandler(orderService, decoders, dlq).Handle)
+	kafkaSubscriber.Handle("order-status", kafka.NewStatusHandler(orderService).Handle)
+	kafkaSubscriber.Handle("order-cancellations", kafka.NewCancellationHandler(orderService).Handle)
 	go kafkaSubscriber.Subscribe(ctx)
 	defer kafkaSubscriber.Close()
+	replayer := kafka.NewReplayer(kafkaConn, orderService, decoders, dbBreaker)
+	defer replayer.Close()
+	relay := outbox.NewRelay(repository.NewOutboxRepository(database), kafkaConn)
+	go relay.Run(ctx)
+	defer relay.Close()
+	orderHandler := handlers.NewOrderHandler(orderService, masker, encoder.Default())
+	ingestHandler := handlers.NewIngestHandler(orderService, repository.NewIdempotencyRepository(database))
+	replayHandler := handlers.NewReplayHandler(replayer)
+	healthHandler := handlers.NewHealthHandler(dbBreaker, kafkaSubscriber)
+	consumerHandler := handlers.NewConsumerHandler(kafkaSubscriber)
+	webhookHandler := handlers.NewWebhookHandler(webhookRepo)
+	exportHandler := handlers.NewExportHandler(export.NewExporter(orderRepo, masker))
+	graphQLSchema, err := graphqlapi.NewSchema(orderService, masker)
+	if err != nil {
+		log.Fatalf("Error init GraphQL schema: %v", err)
+	}
+	graphQLHandler := handlers.NewGraphQLHandler(graphQLSchema)
+
+	authenticator, err := auth.FromConfig()
+	if err != nil {
+		log.Fatalf("Error init auth: %v", err)
+	}
 
-	orderHandler := handlers.NewOrderHandler(orderService)
+	allowedOrigins := config.CORSAllowedOrigins()
+	feedHandler := handlers.NewFeedHandler(broadcaster, masker, time.Duration(feedConf.HeartbeatSec)*time.Second, allowedOrigins)
 
 	r := chi.NewRouter()
 
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   config.CORSAllowedOrigins(),
-		AllowedMethods:   []string{"GET"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
+		AllowedOrigins:   allowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Last-Event-ID", "If-None-Match", "If-Modified-Since", auth.APIKeyHeader, handlers.IdempotencyKeyHeader},
+		ExposedHeaders:   []string{"ETag", "Link", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining"},
+		AllowCredentials: !slices.Contains(allowedOrigins, "*"),
 		MaxAge:           300,
 	}))
 
-	r.Use(middleware.Logger)
-	r.Get("/order/{order_uid}", orderHandler.GetOrder)
+	r.Use(auth.AccessLog)
+	r.Use(tracing.RouteSpanName)
+
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/readyz", healthHandler.Readyz)
+
+	// The order lookup keeps the pre-v1 route name so existing configs still apply, and the
+	// legacy alias below uses the same limiter, so both paths draw from one bucket per client.
+	orderLimit := ratelimit.ForRoute("GET /order/{order_uid}")
+
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Get("/openapi.json", handlers.OpenAPISpec)
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Identify(authenticator))
+
+			r.With(
+				orderLimit,
+				auth.Middleware(authenticator),
+				auth.RequireScope(auth.ScopeReadOrders),
+			).Get("/orders/{order_uid}", orderHandler.GetOrder)
+
+			r.With(
+				ratelimit.ForRoute("POST /orders"),
+				auth.Middleware(authenticator),
+				auth.RequireScope(auth.ScopeWriteOrders),
+				middleware.RequestSize(config.MaxBodyBytes()),
+			).Post("/orders", ingestHandler.CreateOrder)
+
+			r.With(
+				ratelimit.ForRoute("POST /orders:bulk"),
+				auth.Middleware(authenticator),
+				auth.RequireScope(auth.ScopeWriteOrders),
+				middleware.RequestSize(config.MaxBulkBodyBytes()),
+			).Post("/orders:bulk", ingestHandler.BulkCreateOrders)
+
+			r.With(
+				ratelimit.ForRoute("PATCH /orders/{order_uid}/status"),
+				auth.Middleware(authenticator),
+				auth.RequireScope(auth.ScopeWriteOrders),
+				middleware.RequestSize(config.MaxBodyBytes()),
+			).Patch("/orders/{order_uid}/status", orderHandler.ChangeStatus)
+
+			r.With(
+				ratelimit.ForRoute("GET /orders/{order_uid}/history"),
+				auth.Middleware(authenticator),
+				auth.RequireScope(auth.ScopeReadOrders),
+			).Get("/orders/{order_uid}/history", orderHandler.GetStatusHistory)
+
+			r.With(
+				ratelimit.ForRoute("GET /orders/stream"),
+				auth.Middleware(authenticator),
+				auth.RequireScope(auth.ScopeReadOrders),
+			).Get("/orders/stream", feedHandler.Stream)
+
+			r.With(
+				ratelimit.ForRoute("GET /orders/ws"),
+				auth.Middleware(authenticator),
+				auth.RequireScope(auth.ScopeReadOrders),
+			).Get("/orders/ws", feedHandler.WebSocket)
+
+			r.With(
+				ratelimit.ForRoute("/graphql"),
+				auth.Middleware(authenticator),
+				auth.RequireScope(auth.ScopeReadOrders),
+				middleware.RequestSize(config.MaxBodyBytes()),
+			).HandleFunc("/graphql", graphQLHandler.Serve)
+
+			r.Route("/admin/replay", func(r chi.Router) {
+				r.Use(ratelimit.ForRoute("/admin/replay"), auth.Middleware(authenticator), auth.RequireScope(auth.ScopeAdmin))
+				r.With(middleware.RequestSize(config.MaxBodyBytes())).Post("/", replayHandler.StartReplay)
+				r.Get("/", replayHandler.ListReplays)
+				r.Get("/{id}", replayHandler.GetReplay)
+				r.Delete("/{id}", replayHandler.CancelReplay)
+			})
+
+			r.Route("/admin/consumer", func(r chi.Router) {
+				r.Use(ratelimit.ForRoute("/admin/consumer"), auth.Middleware(authenticator), auth.RequireScope(auth.ScopeAdmin))
+				r.Get("/", consumerHandler.State)
+				r.Post("/pause", consumerHandler.Pause)
+				r.Post("/resume", consumerHandler.Resume)
+			})
+
+			r.Route("/admin/webhooks", func(r chi.Router) {
+				r.Use(ratelimit.ForRoute("/admin/webhooks"), auth.Middleware(authenticator), auth.RequireScope(auth.ScopeAdmin))
+				r.With(middleware.RequestSize(config.MaxBodyBytes())).Post("/", webhookHandler.CreateSubscription)
+				r.Get("/", webhookHandler.ListSubscriptions)
+				r.Get("/{id}", webhookHandler.GetSubscription)
+				r.Delete("/{id}", webhookHandler.DeleteSubscription)
+				r.Get("/{id}/deliveries", webhookHandler.ListDeliveries)
+			})
+
+			r.With(
+				ratelimit.ForRoute("/admin/export"),
+				auth.Middleware(authenticator),
+				auth.RequireScope(auth.ScopeAdmin),
+			).Get("/admin/export", exportHandler.Export)
+		})
+	})
+
+	r.With(
+		auth.Identify(authenticator),
+		orderLimit,
+		auth.Middleware(authenticator),
+		auth.RequireScope(auth.ScopeReadOrders),
+	).Get("/order/{order_uid}", orderHandler.LegacyGetOrder)
 
 	fs := http.FileServer(http.Dir(config.StaticDir()))
 	r.Handle("/*", fs)
 
 	srv := &http.Server{
 		Addr:    config.HTTPAddr(),
-		Handler: r,
+		Handler: otelhttp.NewHandler(r, "http.server"),
 	}
 
 	go func() {
@@ -70,6 +261,18 @@ func main() {
 		}
 	}()
 
+	grpcServer, grpcHealth := grpcserver.New(orderService, broadcaster, masker, authenticator, config.GRPCReflection())
+	grpcListener, err := net.Listen("tcp", config.GRPCAddr())
+	if err != nil {
+		log.Fatalf("could not listen on %s: %v\n", config.GRPCAddr(), err)
+	}
+	go func() {
+		log.Println("gRPC service started on", grpcListener.Addr())
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("gRPC server failed: %v", err)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -80,9 +283,24 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
 
+	// WatchOrders streams keep GracefulStop waiting, so they are cut off together with the
+	// HTTP server's deadline.
+	grpcHealth.Shutdown()
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server Shutdown Failed:%+v", err)
 	}
 
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+	}
+
 	log.Println("Server exited properly")
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"test-task/internal/config"
+	"test-task/internal/db"
+	"test-task/internal/export"
+	"test-task/internal/masking"
+	"test-task/internal/repository"
+	"time"
+)
+
+// checkpointFile is what -checkpoint holds while an export is in progress: the request, so
+// that -resume continues the same export, and the last checkpoint.
+type checkpointFile struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Format      string    `json:"format"`
+	Compression string    `json:"compression"`
+	Masked      bool      `json:"masked"`
+	export.Checkpoint
+}
+
+// export writes the orders created in a time range to a file, e.g.
+//
+//	go run ./export -from 2024-05-01T00:00:00Z -to 2024-06-01T00:00:00Z -format csv -compression zstd -out may.csv.zst
+//
+// NDJSON and CSV exports record checkpoints next to the output; after an interruption the
+// same command with -resume continues from the last one.
+func main() {
+	from := flag.String("from", "", "export orders created at or after this RFC 3339 time")
+	to := flag.String("to", "", "export orders created before this RFC 3339 time (default now)")
+	format := flag.String("format", export.FormatNDJSON, "ndjson, csv or parquet")
+	compression := flag.String("compression", export.CompressionNone, "none, gzip or zstd")
+	out := flag.String("out", "", "output file, or - for stdout")
+	checkpointPath := flag.String("checkpoint", "", "checkpoint file (default <out>.checkpoint)")
+	resume := flag.Bool("resume", false, "continue the interrupted export recorded in the checkpoint file")
+	mask := flag.Bool("mask", false, "mask personal data as for callers without pii:read")
+	flag.Parse()
+
+	req := export.Request{
+		From:        parseTime("from", *from, time.Time{}),
+		To:          parseTime("to", *to, time.Now()),
+		Format:      *format,
+		Compression: *compression,
+		Masked:      *mask,
+	}
+	if *out == "" {
+		log.Fatal("-out is required")
+	}
+	if *checkpointPath == "" {
+		*checkpointPath = *out + ".checkpoint"
+	}
+	// Parquet files are only valid once the footer is written, so there is nothing to resume.
+	checkpoints := *out != "-" && *format != export.FormatParquet
+	if *resume && !checkpoints {
+		log.Fatal("-resume needs an ndjson or csv export to a file")
+	}
+
+	var base export.Checkpoint
+	if *resume {
+		saved, err := readCheckpoint(*checkpointPath)
+		if err != nil {
+			log.Fatalf("Failed to read checkpoint: %v", err)
+		}
+		req = export.Request{From: saved.From, To: saved.To, Format: saved.Format, Compression: saved.Compression, Masked: saved.Masked}
+		base = saved.Checkpoint
+		if base.After != "" {
+			cursor, err := repository.ParseOrderCursor(base.After)
+			if err != nil {
+				log.Fatalf("Invalid checkpoint %s: %v", *checkpointPath, err)
+			}
+			req.After = &cursor
+		}
+	} else if checkpoints {
+		if _, err := os.Stat(*checkpointPath); err == nil {
+			log.Fatalf("%s exists: an earlier export was interrupted; use -resume or remove it", *checkpointPath)
+		}
+	}
+	if err := req.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	output, err := openOutput(*out, *resume, base.Bytes)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *out, err)
+	}
+
+	database := db.InitDB()
+	defer database.Close()
+	dbBreaker := db.NewBreaker(database)
+	defer dbBreaker.Close()
+	masker, err := masking.New(config.MaskingRules())
+	if err != nil {
+		log.Fatalf("Error init masking: %v", err)
+	}
+	exporter := export.NewExporter(repository.NewOrderRepository(database, dbBreaker), masker)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	saved := checkpointFile{From: req.From, To: req.To, Format: req.Format, Compression: req.Compression, Masked: req.Masked}
+	var onCheckpoint func(export.Checkpoint) error
+	if checkpoints {
+		onCheckpoint = func(cp export.Checkpoint) error {
+			if err := output.Sync(); err != nil {
+				return err
+			}
+			saved.Checkpoint = export.Checkpoint{After: cp.After, Orders: base.Orders + cp.Orders, Bytes: base.Bytes + cp.Bytes}
+			log.Printf("checkpoint: %d orders, %d bytes", saved.Orders, saved.Bytes)
+			return writeCheckpoint(*checkpointPath, saved)
+		}
+	}
+
+	cp, err := exporter.Run(ctx, output, req, onCheckpoint)
+	if err == nil && output != os.Stdout {
+		err = output.Close()
+	}
+	if err != nil {
+		if checkpoints {
+			log.Fatalf("Export stopped after %d orders: %v; run again with -resume to continue", base.Orders+cp.Orders, err)
+		}
+		log.Fatalf("Export failed: %v", err)
+	}
+
+	if checkpoints {
+		if err := os.Remove(*checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to remove %s: %v", *checkpointPath, err)
+		}
+	}
+	log.Printf("exported %d orders to %s", base.Orders+cp.Orders, *out)
+}
+
+// openOutput creates the output file, or for -resume cuts it back to the last checkpoint
+// and positions it there.
+func openOutput(path string, resume bool, offset int64) (*os.File, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	if !resume {
+		return os.Create(path)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func readCheckpoint(path string) (checkpointFile, error) {
+	var saved checkpointFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return saved, err
+	}
+	return saved, json.Unmarshal(data, &saved)
+}
+
+// writeCheckpoint replaces the checkpoint file atomically, so a crash leaves either the
+// previous checkpoint or the new one.
+func writeCheckpoint(path string, saved checkpointFile) error {
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func parseTime(name, value string, def time.Time) time.Time {
+	if value == "" {
+		return def
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Fatalf("Invalid -%s: %v", name, err)
+	}
+	return t
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"test-task/internal/cache"
+	"test-task/internal/db"
+	"test-task/internal/importer"
+	"test-task/internal/repository"
+	"test-task/internal/service"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// import loads orders from a file through the idempotent save path, e.g.
+//
+//	go run ./import -in model.json
+//	go run ./import -in legacy.csv -mapping legacy-mapping.json -batch 500
+//
+// The input may be gzip or zstd compressed (.gz, .zst), so files from ./export load as is.
+// Records that are not stored go to the rejects file, one JSON object per line.
+func main() {
+	in := flag.String("in", "", "input file, or - for stdin")
+	format := flag.String("format", "", "jsonl, json or csv (default from the file extension)")
+	mappingPath := flag.String("mapping", "", "CSV column mapping: a JSON object from canonical column names to input columns")
+	batchSize := flag.Int("batch", 100, "orders per transaction")
+	rejectsPath := flag.String("rejects", "", "rejects file (default <in>.rejects.jsonl, or rejects.jsonl for stdin)")
+	flag.Parse()
+
+	if *in == "" {
+		log.Fatal("-in is required")
+	}
+	name := *in
+	var input io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *in, err)
+		}
+		defer f.Close()
+		input = f
+	}
+	switch ext := filepath.Ext(name); ext {
+	case ".gz":
+		gz, err := gzip.NewReader(input)
+		if err != nil {
+			log.Fatalf("Failed to read gzip input: %v", err)
+		}
+		input, name = gz, strings.TrimSuffix(name, ext)
+	case ".zst":
+		zr, err := zstd.NewReader(input)
+		if err != nil {
+			log.Fatalf("Failed to read zstd input: %v", err)
+		}
+		defer zr.Close()
+		input, name = zr, strings.TrimSuffix(name, ext)
+	}
+	if *format == "" {
+		*format = formatOf(name)
+	}
+
+	var mapping importer.Mapping
+	if *mappingPath != "" {
+		data, err := os.ReadFile(*mappingPath)
+		if err != nil {
+			log.Fatalf("Failed to read mapping: %v", err)
+		}
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			log.Fatalf("Invalid mapping %s: %v", *mappingPath, err)
+		}
+	}
+	src, err := importer.NewReader(input, *format, mapping)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *in, err)
+	}
+
+	if *rejectsPath == "" {
+		*rejectsPath = "rejects.jsonl"
+		if *in != "-" {
+			*rejectsPath = *in + ".rejects.jsonl"
+		}
+	}
+	rejects := &rejectsFile{path: *rejectsPath}
+	defer rejects.Close()
+
+	database := db.InitDB()
+	defer database.Close()
+	rules, err := service.NewRuleEngineFromConfig()
+	if err != nil {
+		log.Fatalf("Error init business rules: %v", err)
+	}
+	dbBreaker := db.NewBreaker(database)
+	defer dbBreaker.Close()
+	orderService := service.NewOrderService(cache.InitCache(database), repository.NewOrderRepository(database, dbBreaker), rules)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	summary, err := importer.NewImporter(orderService, *batchSize).Run(ctx, src, rejects.Write)
+	log.Printf("inserted %d, duplicate %d, rejected %d", summary.Inserted, summary.Duplicate, summary.Rejected)
+	if summary.Rejected > 0 {
+		log.Printf("rejected records are in %s", *rejectsPath)
+	}
+	if err != nil {
+		rejects.Close()
+		log.Fatalf("Import stopped: %v", err)
+	}
+	json.NewEncoder(os.Stdout).Encode(summary)
+}
+
+func formatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return importer.FormatJSON
+	case ".csv":
+		return importer.FormatCSV
+	}
+	return importer.FormatJSONL
+}
+
+// rejectsFile is created on the first reject, so a clean import leaves no file behind.
+type rejectsFile struct {
+	path string
+	f    *os.File
+	enc  *json.Encoder
+}
+
+func (r *rejectsFile) Write(reject importer.Reject) error {
+	if r.f == nil {
+		f, err := os.Create(r.path)
+		if err != nil {
+			return err
+		}
+		r.f, r.enc = f, json.NewEncoder(f)
+	}
+	return r.enc.Encode(reject)
+}
+
+func (r *rejectsFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type logIdentity struct {
+	id string
+}
+
+type logIdentityKey struct{}
+
+func setLogIdentity(ctx context.Context, id string) {
+	if li, ok := ctx.Value(logIdentityKey{}).(*logIdentity); ok {
+		li.id = id
+	}
+}
+
+// AccessLog logs one line per request including the identity resolved by Middleware further down the chain.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		li := &logIdentity{id: "-"}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), logIdentityKey{}, li)))
+
+		log.Printf("%s %s %s from %s key=%s - %d %dB in %s",
+			r.Method, r.URL.RequestURI(), r.Proto, r.RemoteAddr, li.id,
+			ww.Status(), ww.BytesWritten(), time.Since(start))
+	})
+}
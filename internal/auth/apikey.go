@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"test-task/internal/config"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+type hashedKey struct {
+	ID     string   `json:"id"`
+	Hash   string   `json:"hash"`
+	Scopes []string `json:"scopes"`
+}
+
+type APIKeyStore struct {
+	keys map[string]Principal
+}
+
+func NewAPIKeyStore() *APIKeyStore {
+	return &APIKeyStore{keys: make(map[string]Principal)}
+}
+
+// LoadAPIKeyStore builds the store from keys declared in config and, if set, the hashed-keys file.
+func LoadAPIKeyStore() (*APIKeyStore, error) {
+	store := NewAPIKeyStore()
+	for _, k := range config.AuthAPIKeys() {
+		var err error
+		if k.Hash != "" {
+			err = store.AddHash(k.ID, k.Hash, k.Scopes)
+		} else {
+			err = store.Add(k.ID, k.Key, k.Scopes)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if path := config.AuthKeysFile(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("auth: could not read keys file %s: %w", path, err)
+		}
+		var keys []hashedKey
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("auth: invalid json in keys file %s: %w", path, err)
+		}
+		for _, k := range keys {
+			if err := store.AddHash(k.ID, k.Hash, k.Scopes); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return store, nil
+}
+
+func (s *APIKeyStore) Add(id, key string, scopes []string) error {
+	if key == "" {
+		return fmt.Errorf("auth: api key %q has no key or hash", id)
+	}
+	return s.AddHash(id, HashKey(key), scopes)
+}
+
+// AddHash registers a key by its SHA-256 digest, given as hex with an optional "sha256:" prefix.
+func (s *APIKeyStore) AddHash(id, hash string, scopes []string) error {
+	hash = strings.ToLower(strings.TrimPrefix(hash, "sha256:"))
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("auth: api key %q has invalid sha256 hash", id)
+	}
+	if id == "" {
+		return fmt.Errorf("auth: api key with hash %s has no id", hash[:8])
+	}
+	s.keys[hash] = Principal{ID: id, Scopes: scopes}
+	return nil
+}
+
+func (s *APIKeyStore) Len() int {
+	return len(s.keys)
+}
+
+func (s *APIKeyStore) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+			key = strings.TrimSpace(v)
+		}
+	}
+	if key == "" {
+		return Principal{}, ErrMissingCredentials
+	}
+	p, ok := s.keys[HashKey(key)]
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return p, nil
+}
+
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
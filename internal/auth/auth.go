@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"test-task/internal/config"
+)
+
+const (
+	ScopeReadOrders  = "orders:read"
+	ScopeWriteOrders = "orders:write"
+	ScopeReadPII     = "pii:read"
+	ScopeAdmin       = "admin"
+
+	AnonymousID = "anonymous"
+)
+
+var (
+	ErrMissingCredentials = errors.New("auth: missing credentials")
+	ErrInvalidCredentials = errors.New("auth: invalid credentials")
+)
+
+type Principal struct {
+	ID     string
+	Scopes []string
+}
+
+// HasScope reports whether the principal was granted scope. The admin scope implies every other scope.
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+type anonymous struct{}
+
+// Anonymous lets every request read orders without PII; it is used when authentication is disabled in config.
+func Anonymous() Authenticator {
+	return anonymous{}
+}
+
+func (anonymous) Authenticate(*http.Request) (Principal, error) {
+	return Principal{ID: AnonymousID, Scopes: []string{ScopeReadOrders}}, nil
+}
+
+// FromConfig builds the authenticator for the HTTP API: API keys and/or JWT bearer tokens,
+// depending on what is configured, or Anonymous when authentication is disabled.
+func FromConfig() (Authenticator, error) {
+	if !config.AuthEnabled() {
+		log.Println("WARNING: API authentication is disabled, orders are publicly readable with masked PII")
+		return Anonymous(), nil
+	}
+
+	var authenticators []Authenticator
+
+	keys, err := LoadAPIKeyStore()
+	if err != nil {
+		return nil, err
+	}
+	if keys.Len() > 0 {
+		log.Printf("API key authentication enabled, %d keys loaded", keys.Len())
+		authenticators = append(authenticators, keys)
+	}
+
+	if config.AuthJWKSURL() != "" || config.AuthJWKSFile() != "" {
+		jwtAuth, err := NewJWTAuthenticator()
+		if err != nil {
+			return nil, err
+		}
+		log.Println("JWT bearer authentication enabled")
+		authenticators = append(authenticators, jwtAuth)
+	}
+
+	if len(authenticators) == 0 {
+		return nil, errors.New("auth: enabled but neither api keys nor jwks are configured")
+	}
+	return Chain(authenticators...), nil
+}
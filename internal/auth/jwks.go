@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrUnknownKeyID    = errors.New("auth: unknown jwks key id")
+	ErrJWKSUnavailable = errors.New("auth: jwks unavailable")
+)
+
+// minRefreshInterval stops tokens with made-up key ids from triggering a JWKS fetch on every request.
+const minRefreshInterval = 30 * time.Second
+
+// After a failed fetch the next one waits retryMin, doubling with every failure up to retryMax.
+const (
+	retryMin = time.Second
+	retryMax = 5 * time.Minute
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// JWKS caches the public keys of a JSON Web Key Set loaded from a file or URL
+// and refetches them after refreshInterval or when an unknown key id shows up.
+// Concurrent refetches share one fetch, and failed ones are retried with backoff.
+type JWKS struct {
+	source          string
+	client          *http.Client
+	refreshInterval time.Duration
+	flight          singleflight.Group
+
+	mtx       sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	attempts  uint64
+	lastErr   error
+	failures  int
+	retryAt   time.Time
+}
+
+func NewJWKS(source string, refreshInterval time.Duration) *JWKS {
+	return &JWKS{
+		source:          source,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: refreshInterval,
+		keys:            make(map[string]crypto.PublicKey),
+	}
+}
+
+func (j *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mtx.RLock()
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetchedAt) > j.refreshInterval
+	recent := time.Since(j.fetchedAt) < minRefreshInterval
+	backingOff := time.Now().Before(j.retryAt)
+	seen := j.attempts
+	j.mtx.RUnlock()
+	if ok && !stale {
+		return key, nil
+	}
+	if !ok && !stale && recent {
+		return nil, ErrUnknownKeyID
+	}
+	if backingOff {
+		if ok {
+			return key, nil
+		}
+		return nil, ErrJWKSUnavailable
+	}
+
+	if err := j.refresh(ctx, seen); err != nil {
+		if ok {
+			log.Printf("auth: jwks refresh failed, using cached keys: %v", err)
+			return key, nil
+		}
+		return nil, err
+	}
+
+	j.mtx.RLock()
+	defer j.mtx.RUnlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKeyID
+}
+
+// Refresh refetches the key set. Callers arriving while a fetch is in flight wait for its
+// result instead of starting their own; the fetch itself is not cancelled with ctx, since
+// others may be waiting on it.
+func (j *JWKS) Refresh(ctx context.Context) error {
+	j.mtx.RLock()
+	seen := j.attempts
+	j.mtx.RUnlock()
+	return j.refresh(ctx, seen)
+}
+
+// refresh fetches the key set unless a fetch has finished since the caller saw attempt
+// number seen, in which case it returns that fetch's result.
+func (j *JWKS) refresh(ctx context.Context, seen uint64) error {
+	ch := j.flight.DoChan("", func() (any, error) {
+		j.mtx.RLock()
+		attempts, lastErr := j.attempts, j.lastErr
+		j.mtx.RUnlock()
+		if attempts != seen {
+			return nil, lastErr
+		}
+
+		err := j.fetch(context.WithoutCancel(ctx))
+
+		j.mtx.Lock()
+		defer j.mtx.Unlock()
+		j.attempts++
+		j.lastErr = err
+		if err != nil {
+			j.failures = min(j.failures+1, 16)
+			j.retryAt = time.Now().Add(min(retryMin<<(j.failures-1), retryMax))
+		} else {
+			j.failures = 0
+			j.retryAt = time.Time{}
+		}
+		return nil, err
+	})
+	select {
+	case res := <-ch:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (j *JWKS) fetch(ctx context.Context) error {
+	data, err := j.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	var set jwkSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("auth: invalid jwks json from %s: %w", j.source, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			log.Printf("auth: skipping jwks key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	j.mtx.Lock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	j.mtx.Unlock()
+	return nil
+}
+
+func (j *JWKS) read(ctx context.Context) ([]byte, error) {
+	if !isURL(j.source) {
+		data, err := os.ReadFile(j.source)
+		if err != nil {
+			return nil, fmt.Errorf("auth: could not read jwks file %s: %w", j.source, err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("auth: invalid jwks url %s: %w", j.source, err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to fetch jwks from %s: %w", j.source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: failed to fetch jwks from %s: status %d", j.source, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func isURL(source string) bool {
+	return len(source) > 8 && (source[:7] == "http://" || source[:8] == "https://")
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
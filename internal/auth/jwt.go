@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"test-task/internal/config"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type JWTAuthenticator struct {
+	keys        *JWKS
+	parser      *jwt.Parser
+	scopeClaim  string
+	claimScopes map[string][]string
+}
+
+// jwtSettings is what NewJWTAuthenticator reads from config.
+type jwtSettings struct {
+	source      string
+	refresh     time.Duration
+	issuer      string
+	audience    string
+	leeway      time.Duration
+	scopeClaim  string
+	claimScopes map[string][]string
+}
+
+// NewJWTAuthenticator verifies tokens against the configured JWKS. Issuer and audience are
+// required: without them any token signed by the IdP, whatever service it was issued for,
+// would be accepted.
+func NewJWTAuthenticator() (*JWTAuthenticator, error) {
+	source := config.AuthJWKSURL()
+	if source == "" {
+		source = config.AuthJWKSFile()
+	}
+	return newJWTAuthenticator(jwtSettings{
+		source:      source,
+		refresh:     config.AuthJWKSRefresh(),
+		issuer:      config.AuthJWTIssuer(),
+		audience:    config.AuthJWTAudience(),
+		leeway:      config.AuthJWTLeeway(),
+		scopeClaim:  config.AuthJWTScopeClaim(),
+		claimScopes: config.AuthJWTClaimScopes(),
+	})
+}
+
+func newJWTAuthenticator(s jwtSettings) (*JWTAuthenticator, error) {
+	if s.issuer == "" || s.audience == "" {
+		return nil, errors.New("auth: jwt requires both issuer and audience to be configured")
+	}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(s.leeway),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+	)
+	return &JWTAuthenticator{
+		keys:        NewJWKS(s.source, s.refresh),
+		parser:      parser,
+		scopeClaim:  s.scopeClaim,
+		claimScopes: s.claimScopes,
+	}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || strings.TrimSpace(raw) == "" {
+		return Principal{}, ErrMissingCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(strings.TrimSpace(raw), claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.keys.Key(r.Context(), kid)
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return Principal{}, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	return Principal{ID: "jwt:" + sub, Scopes: a.scopes(claims)}, nil
+}
+
+// scopes maps the configured claim to internal scopes. The claim may be a space-separated
+// string (OAuth "scope") or an array (e.g. "scp", "roles"). Values missing from the mapping
+// are dropped, so an IdP scope that happens to share a name with an internal one grants
+// nothing unless it is mapped.
+func (a *JWTAuthenticator) scopes(claims jwt.MapClaims) []string {
+	var values []string
+	switch v := claims[a.scopeClaim].(type) {
+	case string:
+		values = strings.Fields(v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var scopes []string
+	for _, v := range values {
+		for _, s := range a.claimScopes[v] {
+			if !slices.Contains(scopes, s) {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return scopes
+}
+
+type chain []Authenticator
+
+// Chain tries each authenticator in order and returns the first principal.
+// A request without credentials for one method falls through to the next.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+func (c chain) Authenticate(r *http.Request) (Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrMissingCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return Principal{}, ErrMissingCredentials
+}
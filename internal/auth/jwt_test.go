@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://idp.example.com/"
+	testAudience = "order-service"
+)
+
+type testKey struct {
+	kid    string
+	method jwt.SigningMethod
+	signer crypto.Signer
+}
+
+func newRSAKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{kid: kid, method: jwt.SigningMethodRS256, signer: k}
+}
+
+func newECKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{kid: kid, method: jwt.SigningMethodES256, signer: k}
+}
+
+func (k testKey) jwk() map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": k.kid, "use": "sig", "n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return map[string]string{"kty": "EC", "kid": k.kid, "crv": "P-256", "x": b64(pub.X.FillBytes(make([]byte, size))), "y": b64(pub.Y.FillBytes(make([]byte, size)))}
+	}
+	panic("unsupported key")
+}
+
+func (k testKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// jwksServer serves the keys it currently holds and counts the fetches.
+type jwksServer struct {
+	*httptest.Server
+	mtx     sync.Mutex
+	keys    []testKey
+	status  int
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys ...testKey) *jwksServer {
+	s := &jwksServer{keys: keys, status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if s.status != http.StatusOK {
+			w.WriteHeader(s.status)
+			return
+		}
+		set := map[string][]map[string]string{"keys": {}}
+		for _, k := range s.keys {
+			set["keys"] = append(set["keys"], k.jwk())
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...testKey) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) setStatus(status int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.status = status
+}
+
+func newTestJWTAuthenticator(t *testing.T, source string) *JWTAuthenticator {
+	t.Helper()
+	a, err := newJWTAuthenticator(jwtSettings{
+		source:     source,
+		refresh:    time.Hour,
+		issuer:     testIssuer,
+		audience:   testAudience,
+		scopeClaim: "scope",
+		claimScopes: map[string][]string{
+			"orders.read":  {ScopeReadOrders},
+			"orders.admin": {ScopeReadOrders, ScopeAdmin},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "alice",
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "orders.read",
+	}
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/orders/x", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	rsaKey, ecKey := newRSAKey(t, "rsa-1"), newECKey(t, "ec-1")
+	srv := newJWKSServer(t, rsaKey, ecKey)
+	a := newTestJWTAuthenticator(t, srv.URL)
+
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		c := validClaims()
+		change(c)
+		return c
+	}
+	tests := []struct {
+		name    string
+		key     testKey
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{"valid rsa", rsaKey, validClaims(), false},
+		{"valid ec", ecKey, validClaims(), false},
+		{"expired", rsaKey, with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }), true},
+		{"no expiry", rsaKey, with(func(c jwt.MapClaims) { delete(c, "exp") }), true},
+		{"wrong issuer", rsaKey, with(func(c jwt.MapClaims) { c["iss"] = "https://other.example.com/" }), true},
+		{"wrong audience", ecKey, with(func(c jwt.MapClaims) { c["aud"] = "another-service" }), true},
+		{"audience list", ecKey, with(func(c jwt.MapClaims) { c["aud"] = []string{"another-service", testAudience} }), false},
+		{"no subject", rsaKey, with(func(c jwt.MapClaims) { delete(c, "sub") }), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.Authenticate(bearer(tt.key.sign(t, tt.claims)))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.ID != "jwt:alice" || !slices.Equal(p.Scopes, []string{ScopeReadOrders}) {
+				t.Fatalf("principal = %+v", p)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticateMissingToken(t *testing.T) {
+	a := newTestJWTAuthenticator(t, newJWKSServer(t).URL)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrMissingCredentials) {
+		t.Fatalf("err = %v, want ErrMissingCredentials", err)
+	}
+}
+
+func TestJWTRequiresIssuerAndAudience(t *testing.T) {
+	for _, s := range []jwtSettings{
+		{source: "jwks.json", audience: testAudience},
+		{source: "jwks.json", issuer: testIssuer},
+	} {
+		if _, err := newJWTAuthenticator(s); err == nil {
+			t.Errorf("newJWTAuthenticator(%+v) succeeded", s)
+		}
+	}
+}
+
+func TestJWTScopeMapping(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	a := newTestJWTAuthenticator(t, newJWKSServer(t, key).URL)
+
+	tests := []struct {
+		name  string
+		scope any
+		want  []string
+	}{
+		{"mapped string", "orders.read", []string{ScopeReadOrders}},
+		{"mapped to several", "orders.admin orders.read", []string{ScopeReadOrders, ScopeAdmin}},
+		{"array claim", []string{"orders.read"}, []string{ScopeReadOrders}},
+		{"unmapped dropped", "admin pii:read orders.read", []string{ScopeReadOrders}},
+		{"nothing mapped", "profile email", nil},
+		{"no claim", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			claims["scope"] = tt.scope
+			if tt.scope == nil {
+				delete(claims, "scope")
+			}
+			p, err := a.Authenticate(bearer(key.sign(t, claims)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(p.Scopes, tt.want) {
+				t.Fatalf("scopes = %v, want %v", p.Scopes, tt.want)
+			}
+		})
+	}
+}
+
+// ageKeys makes the cached key set look fetched d ago.
+func ageKeys(j *JWKS, d time.Duration) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	j.fetchedAt = time.Now().Add(-d)
+}
+
+func TestJWKSUnknownKeyIDRefreshes(t *testing.T) {
+	oldKey, newKey := newRSAKey(t, "old"), newECKey(t, "new")
+	srv := newJWKSServer(t, oldKey)
+	a := newTestJWTAuthenticator(t, srv.URL)
+
+	if _, err := a.Authenticate(bearer(oldKey.sign(t, validClaims()))); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+
+	// The IdP rotates its key. Right after a fetch an unknown kid does not refetch.
+	srv.setKeys(oldKey, newKey)
+	if _, err := a.Authenticate(bearer(newKey.sign(t, validClaims()))); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("err = %v, want ErrUnknownKeyID", err)
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+
+	ageKeys(a.keys, minRefreshInterval)
+	if _, err := a.Authenticate(bearer(newKey.sign(t, validClaims()))); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Fatalf("fetches = %d, want 2", n)
+	}
+}
+
+func TestJWKSConcurrentRefreshFetchesOnce(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	srv := newJWKSServer(t, key)
+	a := newTestJWTAuthenticator(t, srv.URL)
+	token := key.sign(t, validClaims())
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := a.Authenticate(bearer(token)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// Callers that arrive after the shared fetch completed find the key cached.
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+}
+
+func TestJWKSBacksOffAfterFailure(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	srv := newJWKSServer(t, key)
+	srv.setStatus(http.StatusInternalServerError)
+	a := newTestJWTAuthenticator(t, srv.URL)
+	token := key.sign(t, validClaims())
+
+	if _, err := a.Authenticate(bearer(token)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+	_, err := a.Authenticate(bearer(token))
+	if !errors.Is(err, ErrJWKSUnavailable) {
+		t.Fatalf("err = %v, want ErrJWKSUnavailable", err)
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1 while backing off", n)
+	}
+
+	srv.setStatus(http.StatusOK)
+	a.keys.mtx.Lock()
+	a.keys.retryAt = time.Now()
+	a.keys.mtx.Unlock()
+	if _, err := a.Authenticate(bearer(token)); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Fatalf("fetches = %d, want 2", n)
+	}
+}
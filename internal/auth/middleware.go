@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+type identity struct {
+	principal Principal
+	err       error
+}
+
+type identityKey struct{}
+
+// Identify authenticates the request without rejecting it: a valid caller's principal is put
+// in the context, and Middleware further down reuses the outcome instead of authenticating
+// again. Middleware placed in between, like the rate limiter, can then tell callers apart
+// and still sees requests that will fail authentication.
+func Identify(a Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := a.Authenticate(r)
+			ctx := context.WithValue(r.Context(), identityKey{}, identity{principal: p, err: err})
+			if err == nil {
+				ctx = WithPrincipal(ctx, p)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Middleware rejects requests that fail authentication with 401 and puts the principal in
+// the context of the rest.
+func Middleware(a Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := r.Context().Value(identityKey{}).(identity)
+			if !ok {
+				id.principal, id.err = a.Authenticate(r)
+			}
+			p, err := id.principal, id.err
+			if err != nil {
+				if errors.Is(err, ErrMissingCredentials) {
+					w.Header().Add("WWW-Authenticate", `Bearer`)
+					w.Header().Add("WWW-Authenticate", `ApiKey header="`+APIKeyHeader+`"`)
+				}
+				log.Printf("Unauthorized request %s %s: %v", r.Method, r.URL.Path, err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			setLogIdentity(r.Context(), p.ID)
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok || !p.HasScope(scope) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
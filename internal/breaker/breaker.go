@@ -0,0 +1,150 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"test-task/internal/metrics"
+	"time"
+)
+
+const (
+	StateClosed = "closed"
+	StateOpen   = "open"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+// ProbeFunc checks whether the protected dependency is healthy again.
+type ProbeFunc func(ctx context.Context) error
+
+// Breaker opens after Threshold consecutive failures. While it is open every call fails
+// fast with ErrOpen and a background probe checks the dependency with exponential backoff
+// between MinBackoff and MaxBackoff; the first successful probe closes the breaker.
+type Breaker struct {
+	name       string
+	threshold  int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	probe      ProbeFunc
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	closed   chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func New(name string, threshold int, minBackoff, maxBackoff time.Duration, probe ProbeFunc) *Breaker {
+	closed := make(chan struct{})
+	close(closed)
+
+	metrics.CircuitOpen.WithLabelValues(name).Set(0)
+	return &Breaker{
+		name:       name,
+		threshold:  max(threshold, 1),
+		minBackoff: minBackoff,
+		maxBackoff: max(maxBackoff, minBackoff),
+		probe:      probe,
+		state:      StateClosed,
+		closed:     closed,
+		stop:       make(chan struct{}),
+	}
+}
+
+// Allow returns ErrOpen if calls should not reach the dependency.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen {
+		return ErrOpen
+	}
+	return nil
+}
+
+// Record reports the outcome of a call; failed tells whether it counts against the dependency.
+func (b *Breaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.trip()
+	}
+}
+
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Wait blocks until the breaker is closed or ctx is done.
+func (b *Breaker) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops a running probe. The breaker stays in its current state.
+func (b *Breaker) Close() {
+	b.stopOnce.Do(func() { close(b.stop) })
+}
+
+func (b *Breaker) trip() {
+	log.Printf("Circuit breaker %s opened after %d consecutive failures", b.name, b.failures)
+	b.state = StateOpen
+	b.closed = make(chan struct{})
+	metrics.CircuitOpen.WithLabelValues(b.name).Set(1)
+	metrics.CircuitTrips.WithLabelValues(b.name).Inc()
+	go b.probeLoop()
+}
+
+func (b *Breaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	log.Printf("Circuit breaker %s closed", b.name)
+	b.state = StateClosed
+	b.failures = 0
+	close(b.closed)
+	metrics.CircuitOpen.WithLabelValues(b.name).Set(0)
+}
+
+func (b *Breaker) probeLoop() {
+	backoff := b.minBackoff
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), max(b.minBackoff, time.Second))
+		err := b.probe(ctx)
+		cancel()
+		if err == nil {
+			b.reset()
+			return
+		}
+
+		backoff = min(backoff*2, b.maxBackoff)
+		log.Printf("Circuit breaker %s probe failed, next in %s: %v", b.name, backoff, err)
+	}
+}
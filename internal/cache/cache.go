@@ -2,7 +2,10 @@ package cache
 
 import (
 	"container/list"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"log"
 	"sync"
@@ -24,6 +27,8 @@ type LRU_Cache struct {
 type entry struct {
 	key   string
 	value model.Order
+	// etag is the content hash of value, computed once when the entry is set.
+	etag string
 }
 
 func NewCache() *LRU_Cache {
@@ -33,16 +38,20 @@ func NewCache() *LRU_Cache {
 	}
 }
 
-func (targ *LRU_Cache) Set(value model.Order) {
+// Set stores value and returns its content hash.
+func (targ *LRU_Cache) Set(value model.Order) string {
+	etag := ContentHash(value)
+
 	targ.mtx.Lock()
 	defer targ.mtx.Unlock()
 	key := value.OrderUID
 	if elem, hit := targ.storage[key]; hit {
 		targ.linkedList.MoveToFront(elem)
-		targ.storage[key].Value.(*entry).value = value
-		return
+		e := targ.storage[key].Value.(*entry)
+		e.value, e.etag = value, etag
+		return etag
 	}
-	newElem := entry{key, value}
+	newElem := entry{key, value, etag}
 	newListElement := targ.linkedList.PushFront(&newElem)
 	targ.storage[key] = newListElement
 
@@ -52,17 +61,36 @@ func (targ *LRU_Cache) Set(value model.Order) {
 			delete(targ.storage, oldest.Value.(*entry).key)
 		}
 	}
+	return etag
 }
 
 func (targ *LRU_Cache) Get(key string) (model.Order, error) {
+	order, _, err := targ.GetWithETag(key)
+	return order, err
+}
+
+// GetWithETag returns the cached order together with its content hash.
+func (targ *LRU_Cache) GetWithETag(key string) (model.Order, string, error) {
 	targ.mtx.Lock()
 	defer targ.mtx.Unlock()
 
 	if elem, hit := targ.storage[key]; hit {
 		targ.linkedList.MoveToFront(elem)
-		return elem.Value.(*entry).value, nil
+		e := elem.Value.(*entry)
+		return e.value, e.etag, nil
+	}
+	return model.Order{}, "", ErrNotFound
+}
+
+// ContentHash is a hex SHA-256 of the order's JSON representation.
+func ContentHash(order model.Order) string {
+	data, err := json.Marshal(order)
+	if err != nil {
+		log.Printf("Failed to hash order %s: %v", order.OrderUID, err)
+		return ""
 	}
-	return model.Order{}, ErrNotFound
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 func (targ *LRU_Cache) LoadFromDB(orders []model.Order) {
@@ -74,7 +102,7 @@ func (targ *LRU_Cache) LoadFromDB(orders []model.Order) {
 func InitCache(db *sql.DB) *LRU_Cache {
 	cache := NewCache()
 	limit := config.CacheLimit()
-	orders, err := repository.NewOrderRepository(db).GetLastNOrders(limit)
+	orders, err := repository.NewOrderRepository(db, nil).GetLastNOrders(limit)
 	if err != nil {
 		log.Fatalf("Error: %v", err)
 	}
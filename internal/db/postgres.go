@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"log"
+	"test-task/internal/breaker"
 	"test-task/internal/config"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -23,3 +24,9 @@ func InitDB() *sql.DB {
 	log.Println("Connected to Data Base")
 	return db
 }
+
+// NewBreaker returns the circuit breaker guarding db; it probes the database with Ping.
+func NewBreaker(db *sql.DB) *breaker.Breaker {
+	minBackoff, maxBackoff := config.DBProbeBackoff()
+	return breaker.New("database", config.DBBreakerThreshold(), minBackoff, maxBackoff, db.PingContext)
+}
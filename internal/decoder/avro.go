@@ -0,0 +1,220 @@
+package decoder
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"test-task/internal/model"
+	"test-task/internal/schemaregistry"
+	"time"
+
+	"github.com/hamba/avro/v2"
+)
+
+const confluentMagicByte = 0
+
+var ErrNotConfluentFramed = errors.New("decoder: payload is not in confluent wire format")
+
+// orderReaderSchema is the Avro view of model.Order. Writer schemas from the registry are
+// resolved against it, so producers may add fields or drop the ones that have defaults.
+const orderReaderSchema = `{
+  "type": "record", "name": "Order", "namespace": "order.v1",
+  "fields": [
+    {"name": "order_uid", "type": "string"},
+    {"name": "track_number", "type": "string"},
+    {"name": "entry", "type": "string"},
+    {"name": "delivery", "type": {"type": "record", "name": "Delivery", "fields": [
+      {"name": "name", "type": "string"},
+      {"name": "phone", "type": "string"},
+      {"name": "zip", "type": "string"},
+      {"name": "city", "type": "string"},
+      {"name": "address", "type": "string"},
+      {"name": "region", "type": "string"},
+      {"name": "email", "type": "string"}
+    ]}},
+    {"name": "payment", "type": {"type": "record", "name": "Payment", "fields": [
+      {"name": "transaction", "type": "string"},
+      {"name": "request_id", "type": "string", "default": ""},
+      {"name": "currency", "type": "string"},
+      {"name": "provider", "type": "string"},
+      {"name": "amount", "type": "long"},
+      {"name": "payment_dt", "type": "long"},
+      {"name": "bank", "type": "string"},
+      {"name": "delivery_cost", "type": "long"},
+      {"name": "goods_total", "type": "long"},
+      {"name": "custom_fee", "type": "long", "default": 0}
+    ]}},
+    {"name": "items", "type": {"type": "array", "items": {"type": "record", "name": "Item", "fields": [
+      {"name": "chrt_id", "type": "long"},
+      {"name": "track_number", "type": "string"},
+      {"name": "price", "type": "long"},
+      {"name": "rid", "type": "string"},
+      {"name": "name", "type": "string"},
+      {"name": "sale", "type": "long", "default": 0},
+      {"name": "size", "type": "string"},
+      {"name": "total_price", "type": "long"},
+      {"name": "nm_id", "type": "long"},
+      {"name": "brand", "type": "string"},
+      {"name": "status", "type": "long"}
+    ]}}},
+    {"name": "locale", "type": "string"},
+    {"name": "internal_signature", "type": "string", "default": ""},
+    {"name": "customer_id", "type": "string"},
+    {"name": "delivery_service", "type": "string"},
+    {"name": "shardkey", "type": "string"},
+    {"name": "sm_id", "type": "long"},
+    {"name": "date_created", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "oof_shard", "type": "string"},
+    {"name": "status", "type": "string", "default": ""}
+  ]
+}`
+
+type avroOrder struct {
+	OrderUID        string       `avro:"order_uid"`
+	TrackNumber     string       `avro:"track_number"`
+	Entry           string       `avro:"entry"`
+	Delivery        avroDelivery `avro:"delivery"`
+	Payment         avroPayment  `avro:"payment"`
+	Items           []avroItem   `avro:"items"`
+	Locale          string       `avro:"locale"`
+	InternalSign    string       `avro:"internal_signature"`
+	CustomerID      string       `avro:"customer_id"`
+	DeliveryService string       `avro:"delivery_service"`
+	ShardKey        string       `avro:"shardkey"`
+	SmID            int64        `avro:"sm_id"`
+	DateCreated     time.Time    `avro:"date_created"`
+	OofShard        string       `avro:"oof_shard"`
+	Status          string       `avro:"status"`
+}
+
+type avroDelivery struct {
+	Name    string `avro:"name"`
+	Phone   string `avro:"phone"`
+	Zip     string `avro:"zip"`
+	City    string `avro:"city"`
+	Address string `avro:"address"`
+	Region  string `avro:"region"`
+	Email   string `avro:"email"`
+}
+
+type avroPayment struct {
+	Transaction  string `avro:"transaction"`
+	RequestID    string `avro:"request_id"`
+	Currency     string `avro:"currency"`
+	Provider     string `avro:"provider"`
+	Amount       int64  `avro:"amount"`
+	PaymentDT    int64  `avro:"payment_dt"`
+	Bank         string `avro:"bank"`
+	DeliveryCost int64  `avro:"delivery_cost"`
+	GoodsTotal   int64  `avro:"goods_total"`
+	CustomFee    int64  `avro:"custom_fee"`
+}
+
+type avroItem struct {
+	ChrtID      int64  `avro:"chrt_id"`
+	TrackNumber string `avro:"track_number"`
+	Price       int64  `avro:"price"`
+	RID         string `avro:"rid"`
+	Name        string `avro:"name"`
+	Sale        int64  `avro:"sale"`
+	Size        string `avro:"size"`
+	TotalPrice  int64  `avro:"total_price"`
+	NmID        int64  `avro:"nm_id"`
+	Brand       string `avro:"brand"`
+	Status      int64  `avro:"status"`
+}
+
+type AvroDecoder struct {
+	registry *schemaregistry.Client
+	reader   avro.Schema
+	compat   *avro.SchemaCompatibility
+}
+
+func NewAvroDecoder(registry *schemaregistry.Client) *AvroDecoder {
+	return &AvroDecoder{
+		registry: registry,
+		reader:   avro.MustParse(orderReaderSchema),
+		compat:   avro.NewSchemaCompatibility(),
+	}
+}
+
+// Decode reads a Confluent-framed Avro payload: magic byte 0, a big-endian 4-byte schema ID,
+// then the Avro binary body encoded with that writer schema. A writer schema that is not
+// cached yet is fetched from the registry within ctx; if the registry cannot be reached the
+// error wraps schemaregistry.ErrUnavailable.
+func (d *AvroDecoder) Decode(ctx context.Context, data []byte) (model.Order, error) {
+	if !IsConfluentFramed(data) {
+		return model.Order{}, ErrNotConfluentFramed
+	}
+	id := int(binary.BigEndian.Uint32(data[1:5]))
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	writer, err := d.registry.Schema(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	schema, err := d.compat.Resolve(d.reader, writer)
+	if err != nil {
+		return model.Order{}, fmt.Errorf("decoder: writer schema %d is incompatible with order schema: %w", id, err)
+	}
+
+	var ao avroOrder
+	if err := avro.Unmarshal(schema, data[5:], &ao); err != nil {
+		return model.Order{}, fmt.Errorf("decoder: invalid avro payload for schema %d: %w", id, err)
+	}
+	return ao.toModel(), nil
+}
+
+func IsConfluentFramed(data []byte) bool {
+	return len(data) > 5 && data[0] == confluentMagicByte
+}
+
+func (ao avroOrder) toModel() model.Order {
+	items := make([]model.Item, 0, len(ao.Items))
+	for _, it := range ao.Items {
+		items = append(items, model.Item{
+			ChrtID:      it.ChrtID,
+			TrackNumber: it.TrackNumber,
+			Price:       int(it.Price),
+			RID:         it.RID,
+			Name:        it.Name,
+			Sale:        int(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int(it.TotalPrice),
+			NmID:        it.NmID,
+			Brand:       it.Brand,
+			Status:      int(it.Status),
+		})
+	}
+	return model.Order{
+		OrderUID:    ao.OrderUID,
+		TrackNumber: ao.TrackNumber,
+		Entry:       ao.Entry,
+		Delivery:    model.Delivery(ao.Delivery),
+		Payment: model.Payment{
+			Transaction:  ao.Payment.Transaction,
+			RequestID:    ao.Payment.RequestID,
+			Currency:     ao.Payment.Currency,
+			Provider:     ao.Payment.Provider,
+			Amount:       int(ao.Payment.Amount),
+			PaymentDT:    ao.Payment.PaymentDT,
+			Bank:         ao.Payment.Bank,
+			DeliveryCost: int(ao.Payment.DeliveryCost),
+			GoodsTotal:   int(ao.Payment.GoodsTotal),
+			CustomFee:    int(ao.Payment.CustomFee),
+		},
+		Items:           items,
+		Locale:          ao.Locale,
+		InternalSign:    ao.InternalSign,
+		CustomerID:      ao.CustomerID,
+		DeliveryService: ao.DeliveryService,
+		ShardKey:        ao.ShardKey,
+		SmID:            int(ao.SmID),
+		DateCreated:     ao.DateCreated,
+		OofShard:        ao.OofShard,
+		Status:          ao.Status,
+	}
+}
@@ -0,0 +1,251 @@
+package decoder
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task/internal/schemaregistry"
+
+	"github.com/hamba/avro/v2"
+)
+
+// writerSchema is what a producer might register: the order schema without the fields
+// that have defaults in the reader schema, plus one the service does not know.
+var writerSchema = func() string {
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(orderReaderSchema), &schema); err != nil {
+		panic(err)
+	}
+	var fields []any
+	for _, f := range schema["fields"].([]any) {
+		switch f.(map[string]any)["name"] {
+		case "internal_signature", "status":
+			continue
+		}
+		fields = append(fields, f)
+	}
+	schema["fields"] = append(fields, map[string]any{"name": "channel", "type": "string"})
+	data, _ := json.Marshal(schema)
+	return string(data)
+}()
+
+// fakeRegistry serves schemas by ID and counts lookups per ID.
+type fakeRegistry struct {
+	*httptest.Server
+	mtx     sync.Mutex
+	schemas map[int]string
+	status  int
+	hits    map[int]int
+}
+
+func newFakeRegistry(t *testing.T, schemas map[int]string) *fakeRegistry {
+	f := &fakeRegistry{schemas: schemas, hits: make(map[int]int)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/schemas/ids/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		f.mtx.Lock()
+		defer f.mtx.Unlock()
+		f.hits[id]++
+		if f.status != 0 {
+			http.Error(w, `{"error_code":50001,"message":"Error in the backend data store"}`, f.status)
+			return
+		}
+		schema, ok := f.schemas[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		json.NewEncoder(w).Encode(map[string]string{"schema": schema})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeRegistry) hitsFor(id int) int {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.hits[id]
+}
+
+func (f *fakeRegistry) setStatus(status int) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.status = status
+}
+
+// frame encodes v with schema in the Confluent wire format under schema id.
+func frame(t *testing.T, schema string, id int, v any) []byte {
+	t.Helper()
+	body, err := avro.Marshal(avro.MustParse(schema), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, 5, 5+len(body))
+	out[0] = confluentMagicByte
+	binary.BigEndian.PutUint32(out[1:5], uint32(id))
+	return append(out, body...)
+}
+
+func testAvroOrder() map[string]any {
+	return map[string]any{
+		"order_uid":    "b563feb7b2b84b6test",
+		"track_number": "WBILMTESTTRACK",
+		"entry":        "WBIL",
+		"delivery": map[string]any{
+			"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+			"address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com",
+		},
+		"payment": map[string]any{
+			"transaction": "b563feb7b2b84b6test", "request_id": "", "currency": "USD", "provider": "wbpay",
+			"amount": int64(1817), "payment_dt": int64(1637907727), "bank": "alpha",
+			"delivery_cost": int64(1500), "goods_total": int64(317), "custom_fee": int64(0),
+		},
+		"items": []any{map[string]any{
+			"chrt_id": int64(9934930), "track_number": "WBILMTESTTRACK", "price": int64(453), "rid": "ab4219087a764ae0btest",
+			"name": "Mascaras", "sale": int64(30), "size": "0", "total_price": int64(317), "nm_id": int64(2389212),
+			"brand": "Vivienne Sabo", "status": int64(202),
+		}},
+		"locale":           "en",
+		"customer_id":      "test",
+		"delivery_service": "meest",
+		"shardkey":         "9",
+		"sm_id":            int64(99),
+		"date_created":     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		"oof_shard":        "1",
+		"channel":          "web",
+	}
+}
+
+func TestAvroDecode(t *testing.T) {
+	registry := newFakeRegistry(t, map[int]string{7: writerSchema})
+	d := NewAvroDecoder(schemaregistry.NewClient(registry.URL, "", ""))
+
+	order, err := d.Decode(context.Background(), frame(t, writerSchema, 7, testAvroOrder()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderUID != "b563feb7b2b84b6test" || order.Payment.Amount != 1817 || order.SmID != 99 {
+		t.Fatalf("order = %+v", order)
+	}
+	if len(order.Items) != 1 || order.Items[0].ChrtID != 9934930 || order.Items[0].Brand != "Vivienne Sabo" {
+		t.Fatalf("items = %+v", order.Items)
+	}
+	if !order.DateCreated.Equal(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)) {
+		t.Fatalf("date_created = %s", order.DateCreated)
+	}
+	// Fields missing from the writer schema take the reader schema's defaults.
+	if order.InternalSign != "" || order.Status != "" {
+		t.Fatalf("defaults not applied: internal_signature=%q status=%q", order.InternalSign, order.Status)
+	}
+}
+
+func TestAvroDecodeCachesSchemaByID(t *testing.T) {
+	registry := newFakeRegistry(t, map[int]string{7: writerSchema, 8: orderReaderSchema})
+	d := NewAvroDecoder(schemaregistry.NewClient(registry.URL, "", ""))
+
+	readerOrder := testAvroOrder()
+	delete(readerOrder, "channel")
+	readerOrder["internal_signature"], readerOrder["status"] = "", "created"
+	messages := [][]byte{
+		frame(t, writerSchema, 7, testAvroOrder()),
+		frame(t, orderReaderSchema, 8, readerOrder),
+		frame(t, writerSchema, 7, testAvroOrder()),
+		frame(t, orderReaderSchema, 8, readerOrder),
+	}
+	for i, m := range messages {
+		if _, err := d.Decode(context.Background(), m); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+	if registry.hitsFor(7) != 1 || registry.hitsFor(8) != 1 {
+		t.Fatalf("registry lookups: id 7 = %d, id 8 = %d, want 1 each", registry.hitsFor(7), registry.hitsFor(8))
+	}
+
+	// Once cached, a schema no longer depends on the registry.
+	registry.setStatus(http.StatusInternalServerError)
+	if _, err := d.Decode(context.Background(), messages[0]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAvroDecodeNotConfluentFramed(t *testing.T) {
+	registry := newFakeRegistry(t, map[int]string{7: writerSchema})
+	d := NewAvroDecoder(schemaregistry.NewClient(registry.URL, "", ""))
+
+	data := frame(t, writerSchema, 7, testAvroOrder())
+	data[0] = 1
+	for name, payload := range map[string][]byte{
+		"unknown magic byte": data,
+		"too short":          {0, 0, 0, 0, 7},
+		"json":               []byte(`{"order_uid":"x"}`),
+	} {
+		if _, err := d.Decode(context.Background(), payload); !errors.Is(err, ErrNotConfluentFramed) {
+			t.Errorf("%s: err = %v, want ErrNotConfluentFramed", name, err)
+		}
+	}
+	if registry.hitsFor(7) != 0 {
+		t.Fatalf("registry was queried for a payload that is not framed")
+	}
+}
+
+func TestAvroDecodeRegistryErrors(t *testing.T) {
+	registry := newFakeRegistry(t, map[int]string{7: writerSchema})
+	d := NewAvroDecoder(schemaregistry.NewClient(registry.URL, "", ""))
+
+	_, err := d.Decode(context.Background(), frame(t, writerSchema, 42, testAvroOrder()))
+	if !errors.Is(err, schemaregistry.ErrSchemaNotFound) || errors.Is(err, schemaregistry.ErrUnavailable) {
+		t.Fatalf("unknown schema id: err = %v, want ErrSchemaNotFound", err)
+	}
+
+	registry.setStatus(http.StatusInternalServerError)
+	_, err = d.Decode(context.Background(), frame(t, writerSchema, 7, testAvroOrder()))
+	if !errors.Is(err, schemaregistry.ErrUnavailable) {
+		t.Fatalf("registry 500: err = %v, want ErrUnavailable", err)
+	}
+
+	// A failed lookup is not cached: the registry is asked again once it is back.
+	registry.setStatus(0)
+	if _, err := d.Decode(context.Background(), frame(t, writerSchema, 7, testAvroOrder())); err != nil {
+		t.Fatal(err)
+	}
+	if registry.hitsFor(7) != 2 {
+		t.Fatalf("registry lookups for id 7 = %d, want 2", registry.hitsFor(7))
+	}
+}
+
+func TestAvroDecodeRegistryUnreachable(t *testing.T) {
+	registry := newFakeRegistry(t, nil)
+	registry.Close()
+	d := NewAvroDecoder(schemaregistry.NewClient(registry.URL, "", ""))
+
+	_, err := d.Decode(context.Background(), frame(t, writerSchema, 7, testAvroOrder()))
+	if !errors.Is(err, schemaregistry.ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestAvroDecodeUsesContext(t *testing.T) {
+	registry := newFakeRegistry(t, map[int]string{7: writerSchema})
+	d := NewAvroDecoder(schemaregistry.NewClient(registry.URL, "", ""))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := d.Decode(ctx, frame(t, writerSchema, 7, testAvroOrder()))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
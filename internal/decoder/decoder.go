@@ -0,0 +1,172 @@
+package decoder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"test-task/internal/model"
+	"test-task/internal/orderpb"
+)
+
+const (
+	SchemaVersionHeader = "schema-version"
+	ContentTypeHeader   = "content-type"
+	// CurrentSchemaVersion is the version matching model.Order; producers should set it.
+	CurrentSchemaVersion = "2"
+	// LegacySchemaVersion is assumed for JSON messages that carry no version at all.
+	LegacySchemaVersion = "1"
+)
+
+const (
+	FormatJSON     = "application/json"
+	FormatProtobuf = "application/x-protobuf"
+	FormatAvro     = "application/vnd.confluent.avro"
+)
+
+var formatAliases = map[string]string{
+	"":                                FormatJSON,
+	"json":                            FormatJSON,
+	"protobuf":                        FormatProtobuf,
+	"avro":                            FormatAvro,
+	"application/avro":                FormatAvro,
+	"avro/binary":                     FormatAvro,
+	"application/vnd.confluent.avro":  FormatAvro,
+	"application/json":                FormatJSON,
+	"application/x-protobuf":          FormatProtobuf,
+	"application/protobuf":            FormatProtobuf,
+	"application/vnd.google.protobuf": FormatProtobuf,
+}
+
+var (
+	ErrUnknownVersion = errors.New("decoder: unknown schema version")
+	ErrUnknownFormat  = errors.New("decoder: unknown content type")
+)
+
+// DecodeFunc decodes a payload of one schema version and upgrades it to the current model.
+// ctx bounds any lookup the decoder needs, such as fetching a schema.
+type DecodeFunc func(ctx context.Context, data []byte) (model.Order, error)
+
+type Registry struct {
+	decoders map[string]map[string]DecodeFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{decoders: make(map[string]map[string]DecodeFunc)}
+}
+
+// Default returns a registry with every order format and schema version this service understands.
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register(FormatJSON, LegacySchemaVersion, decodeV1)
+	r.Register(FormatJSON, CurrentSchemaVersion, decodeV2)
+	r.Register(FormatProtobuf, CurrentSchemaVersion, decodeProtobuf)
+	return r
+}
+
+func (r *Registry) Register(format, version string, fn DecodeFunc) {
+	if r.decoders[format] == nil {
+		r.decoders[format] = make(map[string]DecodeFunc)
+	}
+	r.decoders[format][version] = fn
+}
+
+func (r *Registry) Decode(ctx context.Context, format, version string, data []byte) (model.Order, error) {
+	versions, ok := r.decoders[format]
+	if !ok {
+		return model.Order{}, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	fn, ok := versions[version]
+	if !ok {
+		return model.Order{}, fmt.Errorf("%w: %q for %s", ErrUnknownVersion, version, format)
+	}
+	return fn(ctx, data)
+}
+
+// DetectFormat picks the format of a Kafka message: its content-type header if set,
+// otherwise Confluent Avro when the payload carries the Confluent magic byte (JSON and
+// Protobuf payloads never start with 0x00), otherwise JSON.
+func DetectFormat(contentType string, data []byte) string {
+	if contentType == "" && IsConfluentFramed(data) {
+		return FormatAvro
+	}
+	return FormatOf(contentType)
+}
+
+// FormatOf normalizes a content-type header value; a missing header means JSON.
+func FormatOf(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if format, ok := formatAliases[mediaType]; ok {
+		return format
+	}
+	return mediaType
+}
+
+// VersionOf resolves the schema version of a message: the header value if present,
+// otherwise the top-level "schema_version" field of a JSON payload, otherwise the legacy
+// version. Binary formats without a header are assumed to be current.
+func VersionOf(format, header string, data []byte) string {
+	if header != "" {
+		return header
+	}
+	if format != FormatJSON {
+		return CurrentSchemaVersion
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return LegacySchemaVersion
+	}
+	var envelope struct {
+		SchemaVersion json.RawMessage `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil || len(envelope.SchemaVersion) == 0 {
+		return LegacySchemaVersion
+	}
+	var s string
+	if err := json.Unmarshal(envelope.SchemaVersion, &s); err == nil {
+		return s
+	}
+	var n json.Number
+	if err := json.Unmarshal(envelope.SchemaVersion, &n); err == nil {
+		if i, err := strconv.Atoi(n.String()); err == nil {
+			return strconv.Itoa(i)
+		}
+	}
+	return string(envelope.SchemaVersion)
+}
+
+// decodeV1 handles the original unversioned format, which had no order status:
+// every v1 order starts in the created state regardless of the payload.
+func decodeV1(_ context.Context, data []byte) (model.Order, error) {
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return model.Order{}, err
+	}
+	order.Status = model.StatusCreated
+	order.Tags = nil
+	return order, nil
+}
+
+func decodeV2(_ context.Context, data []byte) (model.Order, error) {
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return model.Order{}, err
+	}
+	order.Tags = nil
+	return order, nil
+}
+
+func decodeProtobuf(_ context.Context, data []byte) (model.Order, error) {
+	order, err := orderpb.Unmarshal(data)
+	if err != nil {
+		return model.Order{}, err
+	}
+	order.Tags = nil
+	return order, nil
+}
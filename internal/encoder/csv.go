@@ -0,0 +1,65 @@
+package encoder
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"test-task/internal/model"
+	"time"
+)
+
+// CSVHeader names the columns of CSVRecords: order fields, then delivery_*, payment_* and
+// item_* columns.
+var CSVHeader = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id",
+	"delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "status", "tags",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address",
+	"delivery_region", "delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+	"payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+	"payment_goods_total", "payment_custom_fee",
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name", "item_sale",
+	"item_size", "item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+// CSVRecords flattens order into one record per item, each repeating the order, delivery
+// and payment columns. An order without items gives one record with empty item columns.
+func CSVRecords(order model.Order) [][]string {
+	d, p := order.Delivery, order.Payment
+	head := []string{
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSign, order.CustomerID,
+		order.DeliveryService, order.ShardKey, strconv.Itoa(order.SmID), order.DateCreated.UTC().Format(time.RFC3339),
+		order.OofShard, order.Status, strings.Join(order.Tags, ";"),
+		d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email,
+		p.Transaction, p.RequestID, p.Currency, p.Provider,
+		strconv.Itoa(p.Amount), strconv.FormatInt(p.PaymentDT, 10), p.Bank, strconv.Itoa(p.DeliveryCost),
+		strconv.Itoa(p.GoodsTotal), strconv.Itoa(p.CustomFee),
+	}
+
+	if len(order.Items) == 0 {
+		return [][]string{append(head, make([]string, len(CSVHeader)-len(head))...)}
+	}
+	records := make([][]string, 0, len(order.Items))
+	for _, it := range order.Items {
+		record := append(slices.Clone(head),
+			strconv.FormatInt(it.ChrtID, 10), it.TrackNumber, strconv.Itoa(it.Price), it.RID, it.Name,
+			strconv.Itoa(it.Sale), it.Size, strconv.Itoa(it.TotalPrice), strconv.FormatInt(it.NmID, 10),
+			it.Brand, strconv.Itoa(it.Status),
+		)
+		records = append(records, record)
+	}
+	return records
+}
+
+func encodeCSV(w io.Writer, order model.Order) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(CSVRecords(order)); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,192 @@
+// Package encoder renders orders in the representations the HTTP API can negotiate.
+package encoder
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"test-task/internal/model"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatXML     = "xml"
+	FormatMsgpack = "msgpack"
+)
+
+var ErrNotAcceptable = errors.New("encoder: no acceptable representation")
+
+// EncodeFunc writes one order to w.
+type EncodeFunc func(w io.Writer, order model.Order) error
+
+// Format is a registered representation. ContentType is sent with responses; MediaTypes
+// lists every type that selects it in Accept.
+type Format struct {
+	Name        string
+	ContentType string
+	MediaTypes  []string
+	Encode      EncodeFunc
+}
+
+// JSON is the order representation the API has always served.
+var JSON = Format{Name: FormatJSON, ContentType: "application/json", MediaTypes: []string{"application/json"}, Encode: encodeJSON}
+
+type Registry struct {
+	formats []Format
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default returns a registry with JSON (the default when the client has no preference),
+// CSV, XML and MessagePack.
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register(JSON)
+	r.Register(Format{Name: FormatCSV, ContentType: "text/csv; charset=utf-8", MediaTypes: []string{"text/csv"}, Encode: encodeCSV})
+	r.Register(Format{Name: FormatXML, ContentType: "application/xml; charset=utf-8", MediaTypes: []string{"application/xml", "text/xml"}, Encode: encodeXML})
+	r.Register(Format{Name: FormatMsgpack, ContentType: "application/msgpack", MediaTypes: []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}, Encode: encodeMsgpack})
+	return r
+}
+
+// Register adds f, replacing a format with the same name. Earlier formats win ties in
+// negotiation, so the first one registered is the default.
+func (r *Registry) Register(f Format) {
+	if i := slices.IndexFunc(r.formats, func(g Format) bool { return g.Name == f.Name }); i >= 0 {
+		r.formats[i] = f
+		return
+	}
+	r.formats = append(r.formats, f)
+}
+
+// Names lists the registered format names in registration order.
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.formats))
+	for i, f := range r.formats {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// ByName returns the format selected by ?format=.
+func (r *Registry) ByName(name string) (Format, error) {
+	for _, f := range r.formats {
+		if strings.EqualFold(f.Name, name) {
+			return f, nil
+		}
+	}
+	return Format{}, fmt.Errorf("%w: unknown format %q", ErrNotAcceptable, name)
+}
+
+type mediaRange struct {
+	typ string
+	q   float64
+}
+
+// Negotiate picks the format for an Accept header: the highest-quality media range that
+// some format matches, more specific ranges first on equal quality. An empty header
+// selects the first registered format.
+func (r *Registry) Negotiate(accept string) (Format, error) {
+	if len(r.formats) == 0 {
+		return Format{}, ErrNotAcceptable
+	}
+	if strings.TrimSpace(accept) == "" {
+		return r.formats[0], nil
+	}
+
+	var ranges []mediaRange
+	excluded := map[string]bool{}
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			excluded[typ] = true
+			continue
+		}
+		ranges = append(ranges, mediaRange{typ: typ, q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return specificity(ranges[i].typ) > specificity(ranges[j].typ)
+	})
+
+	for _, mr := range ranges {
+		for _, f := range r.formats {
+			if f.matches(mr.typ) && !f.excludedBy(excluded) {
+				return f, nil
+			}
+		}
+	}
+	return Format{}, fmt.Errorf("%w for Accept %q", ErrNotAcceptable, accept)
+}
+
+func (f Format) matches(mediaRange string) bool {
+	for _, t := range f.MediaTypes {
+		if mediaRange == "*/*" || mediaRange == t {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok && strings.HasPrefix(t, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Format) excludedBy(excluded map[string]bool) bool {
+	return slices.ContainsFunc(f.MediaTypes, func(t string) bool { return excluded[t] })
+}
+
+func specificity(mediaRange string) int {
+	switch {
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		return 1
+	}
+	return 2
+}
+
+func encodeJSON(w io.Writer, order model.Order) error {
+	return json.NewEncoder(w).Encode(order)
+}
+
+func encodeXML(w io.Writer, order model.Order) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.EncodeElement(order, xml.StartElement{Name: xml.Name{Local: "order"}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// encodeMsgpack uses the JSON field names, so every representation has the same keys.
+func encodeMsgpack(w io.Writer, order model.Order) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(order)
+}
@@ -0,0 +1,206 @@
+// Package export streams orders created in a time range to NDJSON, CSV or Parquet.
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"test-task/internal/config"
+	"test-task/internal/masking"
+	"test-task/internal/model"
+	"test-task/internal/repository"
+	"time"
+)
+
+const (
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+var ErrInvalidRequest = errors.New("export: invalid request")
+
+// Request is one export: the orders created in [From, To). After resumes an interrupted
+// export behind the order it names; a resumed CSV export has no header row, since it
+// continues the earlier output.
+type Request struct {
+	From        time.Time
+	To          time.Time
+	Format      string
+	Compression string
+	After       *repository.OrderCursor
+	Masked      bool
+}
+
+// Validate fills in the default format (ndjson) and compression (none) and checks the rest.
+func (req *Request) Validate() error {
+	if req.Format == "" {
+		req.Format = FormatNDJSON
+	}
+	if req.Compression == "" {
+		req.Compression = CompressionNone
+	}
+	switch {
+	case req.From.IsZero() || req.To.IsZero():
+		return fmt.Errorf("%w: from and to are required", ErrInvalidRequest)
+	case !req.From.Before(req.To):
+		return fmt.Errorf("%w: from must be before to", ErrInvalidRequest)
+	}
+	switch req.Format {
+	case FormatNDJSON, FormatCSV, FormatParquet:
+	default:
+		return fmt.Errorf("%w: unknown format %q (use ndjson, csv or parquet)", ErrInvalidRequest, req.Format)
+	}
+	switch req.Compression {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		return fmt.Errorf("%w: unknown compression %q (use none, gzip or zstd)", ErrInvalidRequest, req.Compression)
+	}
+	return nil
+}
+
+// ContentType is the media type of the export file. Parquet compresses its column chunks
+// instead of the whole file, so it is always application/vnd.apache.parquet.
+func (req Request) ContentType() string {
+	switch {
+	case req.Format == FormatParquet:
+		return "application/vnd.apache.parquet"
+	case req.Compression == CompressionGzip:
+		return "application/gzip"
+	case req.Compression == CompressionZstd:
+		return "application/zstd"
+	case req.Format == FormatCSV:
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// FileName suggests a name for the export file, e.g. orders-20240501T000000Z-20240502T000000Z.csv.gz.
+func (req Request) FileName() string {
+	const layout = "20060102T150405Z"
+	name := fmt.Sprintf("orders-%s-%s.%s", req.From.UTC().Format(layout), req.To.UTC().Format(layout), req.Format)
+	if req.Format == FormatParquet {
+		return name
+	}
+	switch req.Compression {
+	case CompressionGzip:
+		name += ".gz"
+	case CompressionZstd:
+		name += ".zst"
+	}
+	return name
+}
+
+// Checkpoint marks a point up to which the output is complete. For NDJSON and CSV the first
+// Bytes bytes of the output are a valid file holding every order up to and including the
+// one whose cursor token is After, so an interrupted export resumes by truncating the output
+// to Bytes and running again after that cursor. Parquet output only becomes valid when the
+// export finishes.
+type Checkpoint struct {
+	After  string `json:"after,omitempty"`
+	Orders int64  `json:"orders"`
+	Bytes  int64  `json:"bytes"`
+}
+
+type Exporter struct {
+	repo            *repository.OrderRepository
+	masker          *masking.Masker
+	batchSize       int
+	checkpointEvery int
+}
+
+func NewExporter(repo *repository.OrderRepository, masker *masking.Masker) *Exporter {
+	conf := config.ExportConfig()
+	return &Exporter{repo: repo, masker: masker, batchSize: conf.BatchSize, checkpointEvery: conf.CheckpointEvery}
+}
+
+// Run writes the export to w. Every checkpointEvery orders it ends the current compressed
+// segment and calls onCheckpoint, if set; an error from onCheckpoint stops the export. The
+// returned checkpoint is the end of the output. Orders and Bytes count what this run wrote
+// to w, so a resumed export adds them to the checkpoint it resumed from.
+func (e *Exporter) Run(ctx context.Context, w io.Writer, req Request, onCheckpoint func(Checkpoint) error) (Checkpoint, error) {
+	if err := req.Validate(); err != nil {
+		return Checkpoint{}, err
+	}
+
+	out := &countingWriter{w: w}
+	stream, err := newStream(out, req)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	rows, err := newRowWriter(stream, req)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	var cp Checkpoint
+	if req.After != nil {
+		cp.After = req.After.Token()
+	}
+	checkpoint := func() error {
+		if err := rows.Flush(); err != nil {
+			return err
+		}
+		if err := stream.EndSegment(); err != nil {
+			return err
+		}
+		cp.Bytes = out.n
+		if onCheckpoint == nil {
+			return nil
+		}
+		return onCheckpoint(cp)
+	}
+
+	var sinceCheckpoint int
+	rng := repository.ExportRange{From: req.From, To: req.To, After: req.After}
+	err = e.repo.StreamOrders(ctx, rng, e.batchSize, func(batch []model.Order) error {
+		for _, order := range batch {
+			cursor := repository.CursorAt(order)
+			if req.Masked {
+				order = e.masker.MaskOrder(order)
+			}
+			if err := rows.Write(order); err != nil {
+				return fmt.Errorf("error writing order %s: %w", order.OrderUID, err)
+			}
+			cp.After = cursor.Token()
+			cp.Orders++
+			if sinceCheckpoint++; sinceCheckpoint >= e.checkpointEvery {
+				sinceCheckpoint = 0
+				if err := checkpoint(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return cp, err
+	}
+
+	if err := rows.Close(); err != nil {
+		return cp, err
+	}
+	if err := stream.Close(); err != nil {
+		return cp, err
+	}
+	cp.Bytes = out.n
+	return cp, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,206 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"test-task/internal/encoder"
+	"test-task/internal/model"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/parquet-go/parquet-go"
+	pqgzip "github.com/parquet-go/parquet-go/compress/gzip"
+	pqzstd "github.com/parquet-go/parquet-go/compress/zstd"
+)
+
+// stream compresses the output in segments: gzip members or zstd frames, which decoders
+// read back as one stream. EndSegment closes the current one, so everything written so
+// far is a complete file.
+type stream interface {
+	io.Writer
+	EndSegment() error
+	Close() error
+}
+
+func newStream(w io.Writer, req Request) (stream, error) {
+	if req.Format == FormatParquet {
+		return plainStream{w}, nil
+	}
+	switch req.Compression {
+	case CompressionGzip:
+		return &gzipStream{Writer: gzip.NewWriter(w), out: w}, nil
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &zstdStream{Encoder: enc, out: w}, nil
+	}
+	return plainStream{w}, nil
+}
+
+type plainStream struct{ io.Writer }
+
+func (plainStream) EndSegment() error { return nil }
+func (plainStream) Close() error      { return nil }
+
+type gzipStream struct {
+	*gzip.Writer
+	out io.Writer
+}
+
+func (s *gzipStream) EndSegment() error {
+	if err := s.Writer.Close(); err != nil {
+		return err
+	}
+	s.Reset(s.out)
+	return nil
+}
+
+type zstdStream struct {
+	*zstd.Encoder
+	out io.Writer
+}
+
+func (s *zstdStream) EndSegment() error {
+	if err := s.Encoder.Close(); err != nil {
+		return err
+	}
+	s.Reset(s.out)
+	return nil
+}
+
+type rowWriter interface {
+	Write(order model.Order) error
+	Flush() error
+	Close() error
+}
+
+func newRowWriter(w io.Writer, req Request) (rowWriter, error) {
+	switch req.Format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if req.After == nil {
+			if err := cw.Write(encoder.CSVHeader); err != nil {
+				return nil, err
+			}
+		}
+		return csvWriter{cw}, nil
+	case FormatParquet:
+		var options []parquet.WriterOption
+		switch req.Compression {
+		case CompressionGzip:
+			options = append(options, parquet.Compression(&pqgzip.Codec{}))
+		case CompressionZstd:
+			options = append(options, parquet.Compression(&pqzstd.Codec{}))
+		}
+		return parquetWriter{parquet.NewGenericWriter[parquetRow](w, options...)}, nil
+	}
+	bw := bufio.NewWriter(w)
+	return ndjsonWriter{bw: bw, enc: json.NewEncoder(bw)}, nil
+}
+
+type ndjsonWriter struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func (n ndjsonWriter) Write(order model.Order) error { return n.enc.Encode(order) }
+func (n ndjsonWriter) Flush() error                  { return n.bw.Flush() }
+func (n ndjsonWriter) Close() error                  { return n.bw.Flush() }
+
+// csvWriter writes the columns of the order CSV representation, one row per item.
+type csvWriter struct{ w *csv.Writer }
+
+func (c csvWriter) Write(order model.Order) error { return c.w.WriteAll(encoder.CSVRecords(order)) }
+func (c csvWriter) Flush() error                  { c.w.Flush(); return c.w.Error() }
+func (c csvWriter) Close() error                  { return c.Flush() }
+
+// parquetWriter writes a row group per checkpoint; the file footer is written on Close.
+type parquetWriter struct {
+	w *parquet.GenericWriter[parquetRow]
+}
+
+func (p parquetWriter) Write(order model.Order) error {
+	_, err := p.w.Write(parquetRows(order))
+	return err
+}
+func (p parquetWriter) Flush() error { return p.w.Flush() }
+func (p parquetWriter) Close() error { return p.w.Close() }
+
+// parquetRow has the columns of encoder.CSVHeader with their native types.
+type parquetRow struct {
+	OrderUID            string    `parquet:"order_uid"`
+	TrackNumber         string    `parquet:"track_number"`
+	Entry               string    `parquet:"entry"`
+	Locale              string    `parquet:"locale"`
+	InternalSignature   string    `parquet:"internal_signature"`
+	CustomerID          string    `parquet:"customer_id"`
+	DeliveryService     string    `parquet:"delivery_service"`
+	ShardKey            string    `parquet:"shardkey"`
+	SmID                int64     `parquet:"sm_id"`
+	DateCreated         time.Time `parquet:"date_created,timestamp(microsecond)"`
+	OofShard            string    `parquet:"oof_shard"`
+	Status              string    `parquet:"status"`
+	Tags                []string  `parquet:"tags,list"`
+	DeliveryName        string    `parquet:"delivery_name"`
+	DeliveryPhone       string    `parquet:"delivery_phone"`
+	DeliveryZip         string    `parquet:"delivery_zip"`
+	DeliveryCity        string    `parquet:"delivery_city"`
+	DeliveryAddress     string    `parquet:"delivery_address"`
+	DeliveryRegion      string    `parquet:"delivery_region"`
+	DeliveryEmail       string    `parquet:"delivery_email"`
+	PaymentTransaction  string    `parquet:"payment_transaction"`
+	PaymentRequestID    string    `parquet:"payment_request_id"`
+	PaymentCurrency     string    `parquet:"payment_currency"`
+	PaymentProvider     string    `parquet:"payment_provider"`
+	PaymentAmount       int64     `parquet:"payment_amount"`
+	PaymentDT           int64     `parquet:"payment_dt"`
+	PaymentBank         string    `parquet:"payment_bank"`
+	PaymentDeliveryCost int64     `parquet:"payment_delivery_cost"`
+	PaymentGoodsTotal   int64     `parquet:"payment_goods_total"`
+	PaymentCustomFee    int64     `parquet:"payment_custom_fee"`
+	ItemChrtID          int64     `parquet:"item_chrt_id"`
+	ItemTrackNumber     string    `parquet:"item_track_number"`
+	ItemPrice           int64     `parquet:"item_price"`
+	ItemRID             string    `parquet:"item_rid"`
+	ItemName            string    `parquet:"item_name"`
+	ItemSale            int64     `parquet:"item_sale"`
+	ItemSize            string    `parquet:"item_size"`
+	ItemTotalPrice      int64     `parquet:"item_total_price"`
+	ItemNmID            int64     `parquet:"item_nm_id"`
+	ItemBrand           string    `parquet:"item_brand"`
+	ItemStatus          int64     `parquet:"item_status"`
+}
+
+// parquetRows flattens order like encoder.CSVRecords: one row per item, or one row with
+// zero item columns for an order without items.
+func parquetRows(order model.Order) []parquetRow {
+	d, p := order.Delivery, order.Payment
+	head := parquetRow{
+		OrderUID: order.OrderUID, TrackNumber: order.TrackNumber, Entry: order.Entry, Locale: order.Locale,
+		InternalSignature: order.InternalSign, CustomerID: order.CustomerID, DeliveryService: order.DeliveryService,
+		ShardKey: order.ShardKey, SmID: int64(order.SmID), DateCreated: order.DateCreated.UTC(),
+		OofShard: order.OofShard, Status: order.Status, Tags: order.Tags,
+		DeliveryName: d.Name, DeliveryPhone: d.Phone, DeliveryZip: d.Zip, DeliveryCity: d.City,
+		DeliveryAddress: d.Address, DeliveryRegion: d.Region, DeliveryEmail: d.Email,
+		PaymentTransaction: p.Transaction, PaymentRequestID: p.RequestID, PaymentCurrency: p.Currency,
+		PaymentProvider: p.Provider, PaymentAmount: int64(p.Amount), PaymentDT: p.PaymentDT, PaymentBank: p.Bank,
+		PaymentDeliveryCost: int64(p.DeliveryCost), PaymentGoodsTotal: int64(p.GoodsTotal), PaymentCustomFee: int64(p.CustomFee),
+	}
+	if len(order.Items) == 0 {
+		return []parquetRow{head}
+	}
+	rows := make([]parquetRow, len(order.Items))
+	for i, it := range order.Items {
+		row := head
+		row.ItemChrtID, row.ItemTrackNumber, row.ItemPrice, row.ItemRID = it.ChrtID, it.TrackNumber, int64(it.Price), it.RID
+		row.ItemName, row.ItemSale, row.ItemSize, row.ItemTotalPrice = it.Name, int64(it.Sale), it.Size, int64(it.TotalPrice)
+		row.ItemNmID, row.ItemBrand, row.ItemStatus = it.NmID, it.Brand, int64(it.Status)
+		rows[i] = row
+	}
+	return rows
+}
@@ -0,0 +1,148 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"test-task/internal/metrics"
+	"test-task/internal/model"
+	"time"
+)
+
+// ErrEventsLost is returned by Subscribe when the requested Last-Event-ID has already been
+// evicted from the replay buffer; the subscription is still created.
+var ErrEventsLost = errors.New("feed: events after Last-Event-ID are no longer buffered")
+
+type Event struct {
+	ID           uint64              `json:"id"`
+	Type         string              `json:"type"`
+	Time         time.Time           `json:"time"`
+	Order        model.Order         `json:"order"`
+	StatusChange *model.StatusChange `json:"status_change,omitempty"`
+}
+
+// Subscriber receives matching events on C. Dropped is closed when the subscriber is
+// removed, either by Unsubscribe or because it fell more than its buffer behind.
+type Subscriber struct {
+	C       chan Event
+	Dropped chan struct{}
+	filters map[string]string
+}
+
+func (s *Subscriber) wants(e Event) bool {
+	return e.Order.MatchesFilters(s.filters)
+}
+
+// Broadcaster fans order events out to live feed clients and keeps the last events in a
+// ring buffer so reconnecting clients can resume from Last-Event-ID. Event IDs start at the
+// process start time in microseconds, so IDs from before a restart are recognised as lost
+// rather than confused with new events. It implements service.OrderObserver.
+type Broadcaster struct {
+	clientBuffer int
+
+	mu     sync.Mutex
+	nextID uint64
+	ring   []Event
+	start  int
+	subs   map[*Subscriber]struct{}
+}
+
+func NewBroadcaster(bufferSize, clientBuffer int) *Broadcaster {
+	return &Broadcaster{
+		clientBuffer: clientBuffer,
+		nextID:       uint64(time.Now().UnixMicro()),
+		ring:         make([]Event, 0, bufferSize),
+		subs:         make(map[*Subscriber]struct{}),
+	}
+}
+
+func (b *Broadcaster) OrderCreated(ctx context.Context, order model.Order) {
+	b.Publish(Event{Type: model.EventOrderCreated, Time: time.Now().UTC(), Order: order})
+}
+
+func (b *Broadcaster) OrderStatusChanged(ctx context.Context, order model.Order, change model.StatusChange) {
+	b.Publish(Event{Type: model.EventOrderStatusChanged, Time: change.ChangedAt, Order: order, StatusChange: &change})
+}
+
+// Publish numbers e, buffers it and hands it to every matching subscriber without blocking.
+func (b *Broadcaster) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e.ID = b.nextID
+	b.nextID++
+	if len(b.ring) < cap(b.ring) {
+		b.ring = append(b.ring, e)
+	} else if cap(b.ring) > 0 {
+		b.ring[b.start] = e
+		b.start = (b.start + 1) % len(b.ring)
+	}
+
+	for s := range b.subs {
+		if !s.wants(e) {
+			continue
+		}
+		select {
+		case s.C <- e:
+		default:
+			log.Printf("Dropping slow live feed client after event %d", e.ID)
+			b.remove(s)
+			metrics.FeedDropped.Inc()
+		}
+	}
+}
+
+// Subscribe registers a subscriber for events matching filters. With lastEventID > 0 it
+// also returns the buffered events after that ID, so no event is missed or repeated
+// between the backlog and C.
+func (b *Broadcaster) Subscribe(filters map[string]string, lastEventID uint64) (*Subscriber, []Event, error) {
+	s := &Subscriber{
+		C:       make(chan Event, b.clientBuffer),
+		Dropped: make(chan struct{}),
+		filters: filters,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Event
+	var err error
+	if lastEventID > 0 {
+		for i := range b.ring {
+			e := b.ring[(b.start+i)%len(b.ring)]
+			if e.ID > lastEventID && s.wants(e) {
+				backlog = append(backlog, e)
+			}
+		}
+		if oldest := b.oldestID(); lastEventID+1 < oldest {
+			err = ErrEventsLost
+		}
+	}
+
+	b.subs[s] = struct{}{}
+	metrics.FeedClients.Set(float64(len(b.subs)))
+	return s, backlog, err
+}
+
+func (b *Broadcaster) Unsubscribe(s *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(s)
+}
+
+func (b *Broadcaster) remove(s *Subscriber) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.Dropped)
+	metrics.FeedClients.Set(float64(len(b.subs)))
+}
+
+func (b *Broadcaster) oldestID() uint64 {
+	if len(b.ring) == 0 {
+		return b.nextID
+	}
+	return b.ring[b.start].ID
+}
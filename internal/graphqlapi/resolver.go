@@ -0,0 +1,229 @@
+// Package graphqlapi serves orders over GraphQL. Query resolvers load a page of orders
+// without items; the items of the whole page are fetched with one query the first time
+// any order on the page asks for them.
+package graphqlapi
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"test-task/internal/auth"
+	"test-task/internal/breaker"
+	"test-task/internal/masking"
+	"test-task/internal/model"
+	"test-task/internal/repository"
+	"test-task/internal/service"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+const (
+	maxDepth       = 8
+	maxQueryLength = 8 << 10
+	// maxOrdersPerRequest caps the orders one request may load over all of its root fields,
+	// so that aliasing orders(first: 100) many times cannot multiply the page size limit.
+	maxOrdersPerRequest = 5 * service.MaxPageSize
+)
+
+var (
+	errDatabaseUnavailable = errors.New("database unavailable")
+	errTooManyOrders       = fmt.Errorf("query asks for more than %d orders, request fewer fields or smaller pages", maxOrdersPerRequest)
+)
+
+// NewSchema parses the schema and binds it to the order service.
+func NewSchema(orders *service.OrderService, masker *masking.Masker) (*graphql.Schema, error) {
+	return graphql.ParseSchema(schemaSDL, &queryResolver{service: orders, masker: masker},
+		graphql.MaxDepth(maxDepth), graphql.MaxQueryLength(maxQueryLength))
+}
+
+type budgetKey struct{}
+
+// WithBudget gives the request in ctx its allowance of maxOrdersPerRequest orders. Root
+// fields take their page size from it before touching the database; once it is spent,
+// further fields fail. Queries run without a budget are not limited.
+func WithBudget(ctx context.Context) context.Context {
+	b := new(atomic.Int64)
+	b.Store(maxOrdersPerRequest)
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// spend takes n orders from the request's budget.
+func spend(ctx context.Context, n int) error {
+	b, ok := ctx.Value(budgetKey{}).(*atomic.Int64)
+	if ok && b.Add(-int64(n)) < 0 {
+		return errTooManyOrders
+	}
+	return nil
+}
+
+type queryResolver struct {
+	service *service.OrderService
+	masker  *masking.Masker
+}
+
+func (q *queryResolver) Order(ctx context.Context, args struct{ UID string }) (*orderResolver, error) {
+	if err := spend(ctx, 1); err != nil {
+		return nil, err
+	}
+	order, err := q.service.GetOrder(ctx, args.UID)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	// GetOrder already loads the items, so no batch is needed.
+	return &orderResolver{order: q.mask(ctx, order)}, nil
+}
+
+type orderFilterInput struct {
+	CustomerID      *string
+	TrackNumber     *string
+	DeliveryService *string
+	Status          *string
+}
+
+type pageArgs struct {
+	First *int32
+	After *string
+}
+
+func (q *queryResolver) Orders(ctx context.Context, args struct {
+	Filter *orderFilterInput
+	pageArgs
+}) (*connectionResolver, error) {
+	var filter repository.OrderFilter
+	if f := args.Filter; f != nil {
+		filter = repository.OrderFilter{
+			CustomerID:      deref(f.CustomerID),
+			TrackNumber:     deref(f.TrackNumber),
+			DeliveryService: deref(f.DeliveryService),
+			Status:          deref(f.Status),
+		}
+	}
+	return q.list(ctx, filter, args.pageArgs)
+}
+
+func (q *queryResolver) OrdersByCustomer(ctx context.Context, args struct {
+	CustomerID string
+	pageArgs
+}) (*connectionResolver, error) {
+	return q.list(ctx, repository.OrderFilter{CustomerID: args.CustomerID}, args.pageArgs)
+}
+
+func (q *queryResolver) OrdersByTrackNumber(ctx context.Context, args struct {
+	TrackNumber string
+	pageArgs
+}) (*connectionResolver, error) {
+	return q.list(ctx, repository.OrderFilter{TrackNumber: args.TrackNumber}, args.pageArgs)
+}
+
+func (q *queryResolver) list(ctx context.Context, filter repository.OrderFilter, page pageArgs) (*connectionResolver, error) {
+	var first int
+	if page.First != nil {
+		if *page.First < 0 {
+			return nil, errors.New("first must not be negative")
+		}
+		first = int(*page.First)
+	}
+	pageSize := service.DefaultPageSize
+	if first > 0 {
+		pageSize = min(first, service.MaxPageSize)
+	}
+	if err := spend(ctx, pageSize); err != nil {
+		return nil, err
+	}
+	result, err := q.service.ListOrders(ctx, filter, first, deref(page.After), false)
+	if err != nil {
+		return nil, resolverError(err)
+	}
+
+	b := &itemBatch{service: q.service}
+	conn := &connectionResolver{nextCursor: result.NextPageToken}
+	for _, order := range result.Orders {
+		b.uids = append(b.uids, order.OrderUID)
+		conn.nodes = append(conn.nodes, &orderResolver{order: q.mask(ctx, order), batch: b})
+	}
+	return conn, nil
+}
+
+func (q *queryResolver) mask(ctx context.Context, order model.Order) model.Order {
+	if p, _ := auth.PrincipalFromContext(ctx); !p.HasScope(auth.ScopeReadPII) {
+		return q.masker.MaskOrder(order)
+	}
+	return order
+}
+
+// itemBatch loads the items of every order on a page with a single query, on first use.
+type itemBatch struct {
+	service *service.OrderService
+	uids    []string
+
+	once  sync.Once
+	items map[string][]model.Item
+	err   error
+}
+
+func (b *itemBatch) load(ctx context.Context, uid string) ([]model.Item, error) {
+	b.once.Do(func() {
+		b.items, b.err = b.service.OrderItems(ctx, b.uids)
+	})
+	if b.err != nil {
+		return nil, resolverError(b.err)
+	}
+	return b.items[uid], nil
+}
+
+type connectionResolver struct {
+	nodes      []*orderResolver
+	nextCursor string
+}
+
+func (c *connectionResolver) Nodes() []*orderResolver {
+	return c.nodes
+}
+
+func (c *connectionResolver) PageInfo() *pageInfoResolver {
+	return &pageInfoResolver{nextCursor: c.nextCursor}
+}
+
+type pageInfoResolver struct {
+	nextCursor string
+}
+
+func (p *pageInfoResolver) HasNextPage() bool {
+	return p.nextCursor != ""
+}
+
+func (p *pageInfoResolver) EndCursor() *string {
+	if p.nextCursor == "" {
+		return nil
+	}
+	return &p.nextCursor
+}
+
+// resolverError logs unexpected errors and hides them behind a generic message.
+func resolverError(err error) error {
+	switch {
+	case errors.Is(err, breaker.ErrOpen):
+		return errDatabaseUnavailable
+	case errors.Is(err, service.ErrInvalidPageToken):
+		return errors.New("invalid cursor")
+	}
+	log.Printf("GraphQL query failed: %v", err)
+	return errors.New("failed to load orders")
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,130 @@
+package graphqlapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"test-task/internal/model"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+// Long is the schema's 64-bit integer scalar; GraphQL's Int is only 32 bits wide.
+type Long int64
+
+func (Long) ImplementsGraphQLType(name string) bool {
+	return name == "Long"
+}
+
+func (l *Long) UnmarshalGraphQL(input any) error {
+	switch v := input.(type) {
+	case int32:
+		*l = Long(v)
+	case int64:
+		*l = Long(v)
+	case float64:
+		*l = Long(v)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*l = Long(n)
+	default:
+		return fmt.Errorf("wrong type for Long: %T", input)
+	}
+	return nil
+}
+
+func (l Long) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(l), 10), nil
+}
+
+type orderResolver struct {
+	order model.Order
+	// batch is nil when order already carries its items.
+	batch *itemBatch
+}
+
+func (r *orderResolver) OrderUid() string          { return r.order.OrderUID }
+func (r *orderResolver) TrackNumber() string       { return r.order.TrackNumber }
+func (r *orderResolver) Entry() string             { return r.order.Entry }
+func (r *orderResolver) Locale() string            { return r.order.Locale }
+func (r *orderResolver) InternalSignature() string { return r.order.InternalSign }
+func (r *orderResolver) CustomerId() string        { return r.order.CustomerID }
+func (r *orderResolver) DeliveryService() string   { return r.order.DeliveryService }
+func (r *orderResolver) Shardkey() string          { return r.order.ShardKey }
+func (r *orderResolver) SmId() int32               { return int32(r.order.SmID) }
+func (r *orderResolver) OofShard() string          { return r.order.OofShard }
+func (r *orderResolver) Status() string            { return r.order.Status }
+
+func (r *orderResolver) DateCreated() graphql.Time {
+	return graphql.Time{Time: r.order.DateCreated}
+}
+
+func (r *orderResolver) Tags() []string {
+	if r.order.Tags == nil {
+		return []string{}
+	}
+	return r.order.Tags
+}
+
+func (r *orderResolver) Delivery() *deliveryResolver {
+	return &deliveryResolver{r.order.Delivery}
+}
+
+func (r *orderResolver) Payment() *paymentResolver {
+	return &paymentResolver{r.order.Payment}
+}
+
+func (r *orderResolver) Items(ctx context.Context) ([]*itemResolver, error) {
+	items := r.order.Items
+	if r.batch != nil {
+		var err error
+		if items, err = r.batch.load(ctx, r.order.OrderUID); err != nil {
+			return nil, err
+		}
+	}
+	resolvers := make([]*itemResolver, len(items))
+	for i, item := range items {
+		resolvers[i] = &itemResolver{item}
+	}
+	return resolvers, nil
+}
+
+type deliveryResolver struct{ d model.Delivery }
+
+func (r *deliveryResolver) Name() string    { return r.d.Name }
+func (r *deliveryResolver) Phone() string   { return r.d.Phone }
+func (r *deliveryResolver) Zip() string     { return r.d.Zip }
+func (r *deliveryResolver) City() string    { return r.d.City }
+func (r *deliveryResolver) Address() string { return r.d.Address }
+func (r *deliveryResolver) Region() string  { return r.d.Region }
+func (r *deliveryResolver) Email() string   { return r.d.Email }
+
+type paymentResolver struct{ p model.Payment }
+
+func (r *paymentResolver) Transaction() string { return r.p.Transaction }
+func (r *paymentResolver) RequestId() string   { return r.p.RequestID }
+func (r *paymentResolver) Currency() string    { return r.p.Currency }
+func (r *paymentResolver) Provider() string    { return r.p.Provider }
+func (r *paymentResolver) Amount() int32       { return int32(r.p.Amount) }
+func (r *paymentResolver) PaymentDt() Long     { return Long(r.p.PaymentDT) }
+func (r *paymentResolver) Bank() string        { return r.p.Bank }
+func (r *paymentResolver) DeliveryCost() int32 { return int32(r.p.DeliveryCost) }
+func (r *paymentResolver) GoodsTotal() int32   { return int32(r.p.GoodsTotal) }
+func (r *paymentResolver) CustomFee() int32    { return int32(r.p.CustomFee) }
+
+type itemResolver struct{ i model.Item }
+
+func (r *itemResolver) ChrtId() Long        { return Long(r.i.ChrtID) }
+func (r *itemResolver) TrackNumber() string { return r.i.TrackNumber }
+func (r *itemResolver) Price() int32        { return int32(r.i.Price) }
+func (r *itemResolver) Rid() string         { return r.i.RID }
+func (r *itemResolver) Name() string        { return r.i.Name }
+func (r *itemResolver) Sale() int32         { return int32(r.i.Sale) }
+func (r *itemResolver) Size() string        { return r.i.Size }
+func (r *itemResolver) TotalPrice() int32   { return int32(r.i.TotalPrice) }
+func (r *itemResolver) NmId() Long          { return Long(r.i.NmID) }
+func (r *itemResolver) Brand() string       { return r.i.Brand }
+func (r *itemResolver) Status() int32       { return int32(r.i.Status) }
@@ -0,0 +1,172 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"test-task/internal/auth"
+	"test-task/internal/breaker"
+	"test-task/internal/feed"
+	"test-task/internal/masking"
+	"test-task/internal/model"
+	"test-task/internal/orderpb"
+	"test-task/internal/repository"
+	"test-task/internal/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// New builds a gRPC server with OrderService, the standard health service and, if
+// enabled, server reflection. Order RPCs are authenticated like the HTTP API.
+func New(orders *service.OrderService, broadcaster *feed.Broadcaster, masker *masking.Masker, authenticator auth.Authenticator, withReflection bool) (*grpc.Server, *health.Server) {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryAuth(authenticator)),
+		grpc.ChainStreamInterceptor(streamAuth(authenticator)),
+	)
+	orderpb.RegisterOrderServiceServer(srv, &orderServer{
+		service:     orders,
+		broadcaster: broadcaster,
+		masker:      masker,
+	})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(orderpb.OrderService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+
+	if withReflection {
+		reflection.Register(srv)
+	}
+	return srv, healthServer
+}
+
+type orderServer struct {
+	orderpb.UnimplementedOrderServiceServer
+
+	service     *service.OrderService
+	broadcaster *feed.Broadcaster
+	masker      *masking.Masker
+}
+
+func (s *orderServer) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+	order, err := s.service.GetOrder(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, statusError(fmt.Sprintf("get order %s", req.GetOrderUid()), err)
+	}
+	return orderpb.FromModel(s.mask(ctx, order)), nil
+}
+
+func (s *orderServer) ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	if req.GetPageSize() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+	filter := repository.OrderFilter{
+		CustomerID:      req.GetCustomerId(),
+		TrackNumber:     req.GetTrackNumber(),
+		DeliveryService: req.GetDeliveryService(),
+		Status:          req.GetStatus(),
+	}
+	page, err := s.service.ListOrders(ctx, filter, int(req.GetPageSize()), req.GetPageToken(), true)
+	if err != nil {
+		return nil, statusError("list orders", err)
+	}
+
+	resp := &orderpb.ListOrdersResponse{
+		Orders:        make([]*orderpb.Order, 0, len(page.Orders)),
+		NextPageToken: page.NextPageToken,
+	}
+	for _, order := range page.Orders {
+		resp.Orders = append(resp.Orders, orderpb.FromModel(s.mask(ctx, order)))
+	}
+	return resp, nil
+}
+
+// WatchOrders streams the live feed. Like the SSE endpoint it starts with the buffered
+// events after last_event_id, sends an events_lost event when those are gone, and ends the
+// stream with ResourceExhausted when the client cannot keep up.
+func (s *orderServer) WatchOrders(req *orderpb.WatchOrdersRequest, stream orderpb.OrderService_WatchOrdersServer) error {
+	for field := range req.GetFilters() {
+		if !model.IsFilterField(field) {
+			return status.Errorf(codes.InvalidArgument, "unknown filter %q", field)
+		}
+	}
+
+	ctx := stream.Context()
+	sub, backlog, err := s.broadcaster.Subscribe(req.GetFilters(), req.GetLastEventId())
+	defer s.broadcaster.Unsubscribe(sub)
+
+	if errors.Is(err, feed.ErrEventsLost) {
+		if err := stream.Send(&orderpb.OrderEvent{Type: "events_lost", Id: req.GetLastEventId()}); err != nil {
+			return err
+		}
+	}
+	for _, e := range backlog {
+		if err := stream.Send(s.event(ctx, e)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sub.Dropped:
+			return status.Error(codes.ResourceExhausted, "client too slow")
+		case e := <-sub.C:
+			if err := stream.Send(s.event(ctx, e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *orderServer) event(ctx context.Context, e feed.Event) *orderpb.OrderEvent {
+	pe := &orderpb.OrderEvent{
+		Id:    e.ID,
+		Type:  e.Type,
+		Time:  timestamppb.New(e.Time),
+		Order: orderpb.FromModel(s.mask(ctx, e.Order)),
+	}
+	if c := e.StatusChange; c != nil {
+		pe.StatusChange = &orderpb.StatusChange{
+			FromStatus: c.FromStatus,
+			ToStatus:   c.ToStatus,
+			Reason:     c.Reason,
+			Source:     c.Source,
+			ChangedAt:  timestamppb.New(c.ChangedAt),
+		}
+	}
+	return pe
+}
+
+func (s *orderServer) mask(ctx context.Context, order model.Order) model.Order {
+	if p, _ := auth.PrincipalFromContext(ctx); !p.HasScope(auth.ScopeReadPII) {
+		return s.masker.MaskOrder(order)
+	}
+	return order
+}
+
+// statusError maps service errors to gRPC status codes.
+func statusError(op string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound):
+		return status.Error(codes.NotFound, "Order not found")
+	case errors.Is(err, service.ErrInvalidPageToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, breaker.ErrOpen):
+		return status.Error(codes.Unavailable, "Database unavailable")
+	case errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	}
+	log.Printf("Failed to %s: %v", op, err)
+	return status.Error(codes.Internal, "Internal error")
+}
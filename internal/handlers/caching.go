@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"test-task/internal/encoder"
+	"time"
+)
+
+// orderETag builds the strong ETag of an order response from the content hash kept in the
+// cache. Masked and unmasked responses, and each non-JSON format, are different
+// representations and get different tags; JSON keeps the plain hash.
+func orderETag(hash string, masked bool, format string) string {
+	if hash == "" {
+		return ""
+	}
+	if len(hash) > 32 {
+		hash = hash[:32]
+	}
+	if format != "" && format != encoder.FormatJSON {
+		hash += "-" + format
+	}
+	if masked {
+		hash += "-masked"
+	}
+	return `"` + hash + `"`
+}
+
+func setCacheHeaders(w http.ResponseWriter, etag string, modified time.Time, cacheControl string) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	// Whether the response is masked depends on the caller's credentials.
+	w.Header().Add("Vary", "Authorization, X-API-Key")
+}
+
+// notModified evaluates If-None-Match and, only when that header is absent,
+// If-Modified-Since, as RFC 9110 orders them for GET.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if values := r.Header.Values("If-None-Match"); len(values) > 0 {
+		return etag != "" && etagMatches(strings.Join(values, ","), etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// etagMatches applies the weak comparison If-None-Match uses to a comma-separated list.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"test-task/internal/kafka"
+)
+
+type consumerState struct {
+	Paused bool `json:"paused"`
+}
+
+type ConsumerHandler struct {
+	subscriber *kafka.KafkaSubscriber
+}
+
+func NewConsumerHandler(subscriber *kafka.KafkaSubscriber) *ConsumerHandler {
+	return &ConsumerHandler{subscriber: subscriber}
+}
+
+// State handles GET /admin/consumer.
+func (h *ConsumerHandler) State(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, consumerState{Paused: h.subscriber.Paused()})
+}
+
+// Pause handles POST /admin/consumer/pause.
+func (h *ConsumerHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	h.subscriber.Pause()
+	writeJSON(w, http.StatusOK, consumerState{Paused: true})
+}
+
+// Resume handles POST /admin/consumer/resume.
+func (h *ConsumerHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	h.subscriber.Resume()
+	writeJSON(w, http.StatusOK, consumerState{Paused: false})
+}
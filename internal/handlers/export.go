@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"test-task/internal/auth"
+	"test-task/internal/breaker"
+	"test-task/internal/export"
+	"time"
+)
+
+const ExportOrdersTrailer = "X-Export-Orders"
+
+type ExportHandler struct {
+	exporter *export.Exporter
+}
+
+func NewExportHandler(exporter *export.Exporter) *ExportHandler {
+	return &ExportHandler{exporter: exporter}
+}
+
+// Export handles GET /admin/export?from=&to=&format=&compression=. from and to are RFC 3339
+// times (to defaults to now). The file is streamed as it is read and flushed at every
+// checkpoint; the order count is sent as a trailer. An error once streaming has started
+// aborts the response, so a client never mistakes a truncated export for a complete one.
+// Resuming an interrupted export is left to the export command, which keeps the checkpoint.
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := export.Request{Format: q.Get("format"), Compression: q.Get("compression"), To: time.Now()}
+	for name, dst := range map[string]*time.Time{"from": &req.From, "to": &req.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid " + name + ": " + err.Error()})
+				return
+			}
+			*dst = t
+		}
+	}
+	p, _ := auth.PrincipalFromContext(r.Context())
+	req.Masked = !p.HasScope(auth.ScopeReadPII)
+	if err := req.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", req.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": req.FileName()}))
+	w.Header().Set("Trailer", ExportOrdersTrailer)
+
+	out := &startedWriter{w: w}
+	rc := http.NewResponseController(w)
+	cp, err := h.exporter.Run(r.Context(), out, req, func(cp export.Checkpoint) error {
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if !out.started {
+			w.Header().Del("Content-Disposition")
+			w.Header().Del("Trailer")
+			if errors.Is(err, breaker.ErrOpen) {
+				writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "Database unavailable"})
+				return
+			}
+			log.Printf("Export %s..%s failed: %v", req.From.Format(time.RFC3339), req.To.Format(time.RFC3339), err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Export failed"})
+			return
+		}
+		log.Printf("Export %s..%s aborted after %d orders: %v", req.From.Format(time.RFC3339), req.To.Format(time.RFC3339), cp.Orders, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	w.Header().Set(ExportOrdersTrailer, strconv.FormatInt(cp.Orders, 10))
+}
+
+// startedWriter records whether any of the body has been written, after which the status
+// can no longer change.
+type startedWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (s *startedWriter) Write(p []byte) (int, error) {
+	s.started = true
+	return s.w.Write(p)
+}
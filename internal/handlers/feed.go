@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"test-task/internal/auth"
+	"test-task/internal/feed"
+	"test-task/internal/masking"
+	"test-task/internal/model"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	lastEventIDParam = "last_event_id"
+	wsWriteTimeout   = 10 * time.Second
+)
+
+// eventsLost is sent instead of the backlog when the client asked to resume from an event
+// that is no longer buffered.
+type eventsLost struct {
+	Type        string `json:"type"`
+	LastEventID uint64 `json:"last_event_id"`
+}
+
+type FeedHandler struct {
+	broadcaster *feed.Broadcaster
+	masker      *masking.Masker
+	heartbeat   time.Duration
+	upgrader    websocket.Upgrader
+}
+
+func NewFeedHandler(broadcaster *feed.Broadcaster, masker *masking.Masker, heartbeat time.Duration, allowedOrigins []string) *FeedHandler {
+	return &FeedHandler{
+		broadcaster: broadcaster,
+		masker:      masker,
+		heartbeat:   heartbeat,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				for _, allowed := range allowedOrigins {
+					if allowed == "*" || allowed == origin {
+						return true
+					}
+				}
+				return origin == ""
+			},
+		},
+	}
+}
+
+// Stream handles GET /orders/stream as Server-Sent Events. Query parameters named after
+// order fields (delivery_service, customer_id, ...) filter the feed; Last-Event-ID or
+// ?last_event_id= resumes after a reconnect.
+func (h *FeedHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	filters, lastEventID, err := feedParams(r, r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Streaming is not supported"})
+		return
+	}
+
+	sub, backlog, err := h.broadcaster.Subscribe(filters, lastEventID)
+	defer h.broadcaster.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if errors.Is(err, feed.ErrEventsLost) {
+		writeSSE(w, 0, "events_lost", eventsLost{Type: "events_lost", LastEventID: lastEventID})
+	}
+	for _, e := range backlog {
+		writeSSE(w, e.ID, e.Type, h.mask(r, e))
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Dropped:
+			fmt.Fprint(w, "event: dropped\ndata: {\"reason\":\"client too slow\"}\n\n")
+			flusher.Flush()
+			return
+		case e := <-sub.C:
+			writeSSE(w, e.ID, e.Type, h.mask(r, e))
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// WebSocket handles GET /orders/ws: the same feed as Stream, one JSON event per text
+// message and ping frames as heartbeats. Resume with ?last_event_id=.
+func (h *FeedHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	filters, lastEventID, err := feedParams(r, "")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade live feed connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub, backlog, err := h.broadcaster.Subscribe(filters, lastEventID)
+	defer h.broadcaster.Unsubscribe(sub)
+
+	// The client does not send anything; reading just processes pongs and notices when
+	// the connection goes away.
+	closed := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(v any) bool {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(v) == nil
+	}
+
+	if errors.Is(err, feed.ErrEventsLost) && !send(eventsLost{Type: "events_lost", LastEventID: lastEventID}) {
+		return
+	}
+	for _, e := range backlog {
+		if !send(h.mask(r, e)) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-sub.Dropped:
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "client too slow"),
+				time.Now().Add(wsWriteTimeout))
+			return
+		case e := <-sub.C:
+			if !send(h.mask(r, e)) {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *FeedHandler) mask(r *http.Request, e feed.Event) feed.Event {
+	if p, _ := auth.PrincipalFromContext(r.Context()); !p.HasScope(auth.ScopeReadPII) {
+		e.Order = h.masker.MaskOrder(e.Order)
+	}
+	return e
+}
+
+func feedParams(r *http.Request, lastEventIDHeader string) (map[string]string, uint64, error) {
+	filters := map[string]string{}
+	lastEventID := lastEventIDHeader
+	for key, values := range r.URL.Query() {
+		switch {
+		case key == lastEventIDParam:
+			if lastEventID == "" {
+				lastEventID = values[0]
+			}
+		case model.IsFilterField(key):
+			filters[key] = values[0]
+		default:
+			return nil, 0, fmt.Errorf("unknown filter %q", key)
+		}
+	}
+
+	if lastEventID == "" {
+		return filters, 0, nil
+	}
+	id, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid last event id %q", lastEventID)
+	}
+	return filters, id, nil
+}
+
+func writeSSE(w http.ResponseWriter, id uint64, event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode live feed event: %v", err)
+		return
+	}
+	if id > 0 {
+		fmt.Fprintf(w, "id: %d\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
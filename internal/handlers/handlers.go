@@ -1,39 +1,102 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"test-task/internal/auth"
+	"test-task/internal/breaker"
+	"test-task/internal/config"
+	"test-task/internal/encoder"
+	"test-task/internal/masking"
 	"test-task/internal/service"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type OrderHandler struct {
-	service *service.OrderService
+	service      *service.OrderService
+	masker       *masking.Masker
+	cacheControl string
+	encoders     *encoder.Registry
 }
 
-func NewOrderHandler(service *service.OrderService) *OrderHandler {
-	return &OrderHandler{service: service}
+func NewOrderHandler(service *service.OrderService, masker *masking.Masker, encoders *encoder.Registry) *OrderHandler {
+	return &OrderHandler{service: service, masker: masker, cacheControl: config.OrderCacheControl(), encoders: encoders}
 }
 
+// GetOrder handles GET /api/v1/orders/{order_uid}. The response carries a strong ETag and
+// Last-Modified, and conditional requests that still match get 304 Not Modified. The
+// representation is chosen by ?format= or, without it, by the Accept header.
 func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	format, err := h.negotiate(r)
+	if err != nil {
+		writeJSON(w, http.StatusNotAcceptable, notAcceptableResponse{
+			Error:     err.Error(),
+			Supported: h.encoders.Names(),
+		})
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	h.writeOrder(w, r, format)
+}
+
+func (h *OrderHandler) writeOrder(w http.ResponseWriter, r *http.Request, format encoder.Format) {
 	orderUID := chi.URLParam(r, "order_uid")
 	if orderUID == "" {
-		http.Error(w, "Order UID is required", http.StatusBadRequest)
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "Order UID is required"})
 		return
 	}
 
-	order, err := h.service.GetOrder(orderUID)
+	order, hash, err := h.service.GetOrderWithETag(r.Context(), orderUID)
+	if errors.Is(err, breaker.ErrOpen) {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "Database unavailable"})
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to get order %s: %v", orderUID, err)
-		http.Error(w, "Order not found", http.StatusNotFound)
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "Order not found"})
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(order); err != nil {
-		log.Printf("Failed to encode order %s to JSON: %v", orderUID, err)
+	p, _ := auth.PrincipalFromContext(r.Context())
+	masked := !p.HasScope(auth.ScopeReadPII)
+	if masked {
+		order = h.masker.MaskOrder(order)
+	}
+
+	etag := orderETag(hash, masked, format.Name)
+	setCacheHeaders(w, etag, order.UpdatedAt, h.cacheControl)
+	if notModified(r, etag, order.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	if err := format.Encode(w, order); err != nil {
+		log.Printf("Failed to encode order %s to %s: %v", orderUID, format.Name, err)
 		http.Error(w, "Failed to write response", http.StatusInternalServerError)
 	}
 }
+
+type notAcceptableResponse struct {
+	Error     string   `json:"error"`
+	Supported []string `json:"supported"`
+}
+
+func (h *OrderHandler) negotiate(r *http.Request) (encoder.Format, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		return h.encoders.ByName(name)
+	}
+	return h.encoders.Negotiate(r.Header.Get("Accept"))
+}
+
+// LegacyGetOrder handles GET /order/{order_uid}, the pre-/api/v1 path of GetOrder. The
+// response is the JSON one it has always been, whatever the Accept header says, with
+// headers pointing clients to the versioned path.
+func (h *OrderHandler) LegacyGetOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", "</api/v1/orders/"+url.PathEscape(chi.URLParam(r, "order_uid"))+`>; rel="successor-version"`)
+	h.writeOrder(w, r, encoder.JSON)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"test-task/internal/breaker"
+	"test-task/internal/kafka"
+)
+
+type readiness struct {
+	Status          string `json:"status"`
+	DatabaseCircuit string `json:"database_circuit"`
+	ConsumerPaused  bool   `json:"consumer_paused"`
+}
+
+type HealthHandler struct {
+	dbBreaker  *breaker.Breaker
+	subscriber *kafka.KafkaSubscriber
+}
+
+func NewHealthHandler(dbBreaker *breaker.Breaker, subscriber *kafka.KafkaSubscriber) *HealthHandler {
+	return &HealthHandler{dbBreaker: dbBreaker, subscriber: subscriber}
+}
+
+// Readyz handles GET /readyz. The service is not ready while the database circuit is open;
+// a manually paused consumer is reported but does not affect readiness.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	resp := readiness{
+		Status:          "ready",
+		DatabaseCircuit: h.dbBreaker.State(),
+		ConsumerPaused:  h.subscriber.Paused(),
+	}
+	if resp.DatabaseCircuit == breaker.StateOpen {
+		resp.Status = "unavailable"
+		writeJSON(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"test-task/internal/auth"
+	"test-task/internal/breaker"
+	"test-task/internal/model"
+	"test-task/internal/repository"
+	"test-task/internal/service"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 200
+	maxNDJSONLineBytes   = 4 << 20
+)
+
+const (
+	ResultCreated   = "created"
+	ResultDuplicate = "duplicate"
+	ResultInvalid   = "invalid"
+	ResultFailed    = "failed"
+)
+
+type IngestResult struct {
+	Index    int                  `json:"index"`
+	OrderUID string               `json:"order_uid,omitempty"`
+	Status   string               `json:"status"`
+	Errors   []service.FieldError `json:"errors,omitempty"`
+
+	err error
+}
+
+type BulkSummary struct {
+	Created   int `json:"created"`
+	Duplicate int `json:"duplicate"`
+	Invalid   int `json:"invalid"`
+	Failed    int `json:"failed"`
+}
+
+type BulkResponse struct {
+	Summary BulkSummary    `json:"summary"`
+	Results []IngestResult `json:"results"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type IngestHandler struct {
+	service     *service.OrderService
+	idempotency *repository.IdempotencyRepository
+}
+
+func NewIngestHandler(service *service.OrderService, idempotency *repository.IdempotencyRepository) *IngestHandler {
+	return &IngestHandler{service: service, idempotency: idempotency}
+}
+
+// CreateOrder handles POST /orders with a single order in the body.
+func (h *IngestHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	h.idempotent(w, r, "POST /orders", func(body io.Reader) (int, any) {
+		var order model.Order
+		if err := json.NewDecoder(body).Decode(&order); err != nil {
+			return http.StatusBadRequest, errorResponse{Error: "invalid order JSON: " + err.Error()}
+		}
+
+		res := h.ingest(r, 0, order)
+		switch res.Status {
+		case ResultCreated:
+			return http.StatusCreated, res
+		case ResultDuplicate:
+			return http.StatusOK, res
+		case ResultInvalid:
+			return http.StatusUnprocessableEntity, res
+		}
+		if errors.Is(res.err, breaker.ErrOpen) {
+			return http.StatusServiceUnavailable, errorResponse{Error: "Database unavailable"}
+		}
+		return http.StatusInternalServerError, res
+	})
+}
+
+// BulkCreateOrders handles POST /orders:bulk with either a JSON array of orders
+// or an NDJSON stream (Content-Type: application/x-ndjson), one order per line.
+// It stops at the first order that fails on an open database circuit.
+func (h *IngestHandler) BulkCreateOrders(w http.ResponseWriter, r *http.Request) {
+	h.idempotent(w, r, "POST /orders:bulk", func(body io.Reader) (int, any) {
+		resp := BulkResponse{Results: []IngestResult{}}
+
+		var err error
+		if isNDJSON(r) {
+			err = h.ingestNDJSON(r, body, &resp)
+		} else {
+			err = h.ingestArray(r, body, &resp)
+		}
+		if errors.Is(err, breaker.ErrOpen) {
+			resp.Error = "Database unavailable"
+			return http.StatusServiceUnavailable, resp
+		}
+		if err != nil {
+			resp.Error = err.Error()
+			return http.StatusBadRequest, resp
+		}
+		return http.StatusOK, resp
+	})
+}
+
+func (h *IngestHandler) ingestNDJSON(r *http.Request, body io.Reader, resp *BulkResponse) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), maxNDJSONLineBytes)
+
+	for index := 0; scanner.Scan(); {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		res := h.decodeAndIngest(r, index, line)
+		resp.add(res)
+		if errors.Is(res.err, breaker.ErrOpen) {
+			return res.err
+		}
+		index++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read ndjson stream: %w", err)
+	}
+	return nil
+}
+
+func (h *IngestHandler) ingestArray(r *http.Request, body io.Reader, resp *BulkResponse) error {
+	dec := json.NewDecoder(body)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return errors.New("body must be a JSON array of orders or an NDJSON stream")
+	}
+	for index := 0; dec.More(); index++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("malformed JSON at order %d: %w", index, err)
+		}
+		res := h.decodeAndIngest(r, index, raw)
+		resp.add(res)
+		if errors.Is(res.err, breaker.ErrOpen) {
+			return res.err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("malformed JSON array: %w", err)
+	}
+	return nil
+}
+
+func (h *IngestHandler) decodeAndIngest(r *http.Request, index int, data []byte) IngestResult {
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return IngestResult{
+			Index:  index,
+			Status: ResultInvalid,
+			Errors: []service.FieldError{{Field: "", Message: "invalid order JSON: " + err.Error()}},
+		}
+	}
+	return h.ingest(r, index, order)
+}
+
+func (h *IngestHandler) ingest(r *http.Request, index int, order model.Order) IngestResult {
+	res := IngestResult{Index: index, OrderUID: order.OrderUID}
+
+	// Tags are set through the rules engine, never by the client.
+	order.Tags = nil
+	err := h.service.ProcessNewOrder(r.Context(), order)
+	var verr *service.ValidationError
+	switch {
+	case err == nil:
+		res.Status = ResultCreated
+	case errors.Is(err, service.ErrDuplicateOrder):
+		res.Status = ResultDuplicate
+	case errors.As(err, &verr):
+		res.Status = ResultInvalid
+		res.Errors = verr.Fields
+	default:
+		res.Status = ResultFailed
+		res.err = err
+	}
+	return res
+}
+
+func (resp *BulkResponse) add(res IngestResult) {
+	switch res.Status {
+	case ResultCreated:
+		resp.Summary.Created++
+	case ResultDuplicate:
+		resp.Summary.Duplicate++
+	case ResultInvalid:
+		resp.Summary.Invalid++
+	default:
+		resp.Summary.Failed++
+	}
+	resp.Results = append(resp.Results, res)
+}
+
+// idempotent runs process at most once per Idempotency-Key and caller. The key is reserved
+// before processing, so a concurrent request with the same key gets 409 instead of storing
+// the orders a second time. A repeated request with the same key and body gets the stored
+// response back; the same key with a different body is rejected. A request that fails with
+// a 5xx releases the key so that it can be retried. Requests without the header are
+// processed as usual.
+func (h *IngestHandler) idempotent(w http.ResponseWriter, r *http.Request, route string, process func(body io.Reader) (int, any)) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		status, resp := process(r.Body)
+		writeJSON(w, status, resp)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "Idempotency-Key is too long"})
+		return
+	}
+
+	p, _ := auth.PrincipalFromContext(r.Context())
+	storeKey := p.ID + ":" + route + ":" + key
+
+	reserved, err := h.idempotency.Reserve(r.Context(), storeKey)
+	if err != nil {
+		log.Printf("Failed to reserve idempotency key: %v", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to check Idempotency-Key"})
+		return
+	}
+	if !reserved {
+		h.replay(w, r, storeKey)
+		return
+	}
+
+	// The outcome is stored even if the client goes away mid-request.
+	storeCtx := context.WithoutCancel(r.Context())
+	hasher := sha256.New()
+	status, resp := process(io.TeeReader(r.Body, hasher))
+	if _, err := io.Copy(hasher, r.Body); err != nil {
+		log.Printf("Failed to drain request body: %v", err)
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Failed to encode ingest response: %v", err)
+		status, body = http.StatusInternalServerError, nil
+	}
+	if status < http.StatusInternalServerError {
+		err = h.idempotency.Save(storeCtx, storeKey, repository.IdempotentResponse{
+			RequestHash: hexSum(hasher),
+			StatusCode:  status,
+			Body:        body,
+		})
+	} else {
+		err = h.idempotency.Release(storeCtx, storeKey)
+	}
+	if err != nil {
+		log.Printf("Failed to store idempotency key: %v", err)
+	}
+
+	if body == nil {
+		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// replay answers a request whose Idempotency-Key is already taken.
+func (h *IngestHandler) replay(w http.ResponseWriter, r *http.Request, storeKey string) {
+	stored, err := h.idempotency.Get(r.Context(), storeKey)
+	if errors.Is(err, repository.ErrIdempotencyKeyNotFound) || (err == nil && stored.StatusCode == 0) {
+		// Still being processed, or released by a failed request a moment ago.
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusConflict, errorResponse{Error: "a request with this Idempotency-Key is still being processed"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up idempotency key: %v", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to check Idempotency-Key"})
+		return
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r.Body); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "failed to read request body"})
+		return
+	}
+	if hexSum(hasher) != stored.RequestHash {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "Idempotency-Key was already used with a different request body"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.Body)
+}
+
+func isNDJSON(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-ndjson" || mediaType == "application/ndjson"
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response to JSON: %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes every HTTP endpoint. Keep it in step with the routes in cmd/main.go.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec handles GET /api/v1/openapi.json.
+func OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task/internal/auth"
+	"test-task/internal/breaker"
+	"test-task/internal/cache"
+	"test-task/internal/encoder"
+	"test-task/internal/export"
+	"test-task/internal/graphqlapi"
+	"test-task/internal/kafka"
+	"test-task/internal/masking"
+	"test-task/internal/metrics"
+	"test-task/internal/model"
+	"test-task/internal/repository"
+	"test-task/internal/service"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/go-chi/chi/v5"
+)
+
+// testKeys maps the API keys the test authenticator accepts to their scopes.
+var testKeys = map[string][]string{
+	"reader": {auth.ScopeReadOrders},
+	"writer": {auth.ScopeReadOrders, auth.ScopeWriteOrders},
+	"admin":  {auth.ScopeAdmin},
+}
+
+type testAuthenticator struct{}
+
+func (testAuthenticator) Authenticate(r *http.Request) (auth.Principal, error) {
+	key := r.Header.Get(auth.APIKeyHeader)
+	if key == "" {
+		return auth.Principal{}, auth.ErrMissingCredentials
+	}
+	scopes, ok := testKeys[key]
+	if !ok {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+	return auth.Principal{ID: "key:" + key, Scopes: scopes}, nil
+}
+
+// testServer wires the real handlers the way cmd/main.go does, minus rate limiting. There
+// is no database: the breaker is open, so every call that would reach it fails fast with
+// breaker.ErrOpen, and orders are served from the cache.
+func testServer(t *testing.T) (http.Handler, model.Order) {
+	t.Helper()
+
+	dbBreaker := breaker.New("openapi-test", 1, time.Hour, time.Hour, func(context.Context) error {
+		return errors.New("no database in tests")
+	})
+	dbBreaker.Record(true)
+	t.Cleanup(dbBreaker.Close)
+
+	data, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		t.Fatal(err)
+	}
+	order.Status = model.StatusCreated
+	order.UpdatedAt = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	orderCache := cache.NewCache()
+	orderCache.Set(order)
+
+	orderRepo := repository.NewOrderRepository(nil, dbBreaker)
+	orderService := service.NewOrderService(orderCache, orderRepo, service.NewRuleEngine())
+	masker, err := masking.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscriber := kafka.NewKafkaSubscriber(nil, dbBreaker)
+	schema, err := graphqlapi.NewSchema(orderService, masker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orderHandler := NewOrderHandler(orderService, masker, encoder.Default())
+	ingestHandler := NewIngestHandler(orderService, repository.NewIdempotencyRepository(nil))
+	replayHandler := NewReplayHandler(kafka.NewReplayer(nil, orderService, nil, dbBreaker))
+	healthHandler := NewHealthHandler(dbBreaker, subscriber)
+	consumerHandler := NewConsumerHandler(subscriber)
+	webhookHandler := NewWebhookHandler(repository.NewWebhookRepository(nil))
+	exportHandler := NewExportHandler(export.NewExporter(orderRepo, masker))
+	graphQLHandler := NewGraphQLHandler(schema)
+
+	var authenticator testAuthenticator
+	r := chi.NewRouter()
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/readyz", healthHandler.Readyz)
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Get("/openapi.json", OpenAPISpec)
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Identify(authenticator))
+			read := r.With(auth.Middleware(authenticator), auth.RequireScope(auth.ScopeReadOrders))
+			write := r.With(auth.Middleware(authenticator), auth.RequireScope(auth.ScopeWriteOrders))
+			admin := r.With(auth.Middleware(authenticator), auth.RequireScope(auth.ScopeAdmin))
+
+			read.Get("/orders/{order_uid}", orderHandler.GetOrder)
+			write.Post("/orders", ingestHandler.CreateOrder)
+			write.Post("/orders:bulk", ingestHandler.BulkCreateOrders)
+			write.Patch("/orders/{order_uid}/status", orderHandler.ChangeStatus)
+			read.Get("/orders/{order_uid}/history", orderHandler.GetStatusHistory)
+			read.HandleFunc("/graphql", graphQLHandler.Serve)
+
+			admin.Post("/admin/replay", replayHandler.StartReplay)
+			admin.Get("/admin/replay", replayHandler.ListReplays)
+			admin.Get("/admin/replay/{id}", replayHandler.GetReplay)
+			admin.Delete("/admin/replay/{id}", replayHandler.CancelReplay)
+			admin.Get("/admin/consumer", consumerHandler.State)
+			admin.Post("/admin/consumer/pause", consumerHandler.Pause)
+			admin.Post("/admin/consumer/resume", consumerHandler.Resume)
+			admin.Post("/admin/webhooks", webhookHandler.CreateSubscription)
+			admin.Get("/admin/webhooks/{id}", webhookHandler.GetSubscription)
+			admin.Delete("/admin/webhooks/{id}", webhookHandler.DeleteSubscription)
+			admin.Get("/admin/webhooks/{id}/deliveries", webhookHandler.ListDeliveries)
+			admin.Get("/admin/export", exportHandler.Export)
+		})
+	})
+	r.With(
+		auth.Identify(authenticator),
+		auth.Middleware(authenticator),
+		auth.RequireScope(auth.ScopeReadOrders),
+	).Get("/order/{order_uid}", orderHandler.LegacyGetOrder)
+	return r, order
+}
+
+func loadSpec(t *testing.T) routers.Router {
+	t.Helper()
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(openAPISpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		t.Fatalf("openapi.json is not a valid OpenAPI document: %v", err)
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+type apiCase struct {
+	name   string
+	method string
+	path   string
+	header map[string]string
+	body   string
+	want   int
+}
+
+func TestResponsesMatchOpenAPISpec(t *testing.T) {
+	spec := loadSpec(t)
+	srv, order := testServer(t)
+
+	validOrder, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := order
+	bad.Delivery.Email = "not-an-email"
+	invalidOrder, err := json.Marshal(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+order.OrderUID, nil)
+		req.Header.Set(auth.APIKeyHeader, "reader")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec.Header().Get("ETag")
+	}()
+	reader := map[string]string{auth.APIKeyHeader: "reader"}
+	writer := map[string]string{auth.APIKeyHeader: "writer", "Content-Type": "application/json"}
+	admin := map[string]string{auth.APIKeyHeader: "admin", "Content-Type": "application/json"}
+	with := func(h map[string]string, key, value string) map[string]string {
+		out := map[string]string{key: value}
+		for k, v := range h {
+			out[k] = v
+		}
+		return out
+	}
+	orderPath := "/api/v1/orders/" + order.OrderUID
+
+	cases := []apiCase{
+		{"spec", http.MethodGet, "/api/v1/openapi.json", nil, "", http.StatusOK},
+		{"metrics", http.MethodGet, "/metrics", nil, "", http.StatusOK},
+		{"readyz with the database down", http.MethodGet, "/readyz", nil, "", http.StatusServiceUnavailable},
+
+		{"order", http.MethodGet, orderPath, reader, "", http.StatusOK},
+		{"order as csv", http.MethodGet, orderPath + "?format=csv", reader, "", http.StatusOK},
+		{"order not modified", http.MethodGet, orderPath, with(reader, "If-None-Match", etag), "", http.StatusNotModified},
+		{"order in unknown format", http.MethodGet, orderPath, with(reader, "Accept", "image/png"), "", http.StatusNotAcceptable},
+		{"order not cached, database down", http.MethodGet, "/api/v1/orders/unknown", reader, "", http.StatusServiceUnavailable},
+		{"order without credentials", http.MethodGet, orderPath, nil, "", http.StatusUnauthorized},
+		{"order with a bad key", http.MethodGet, orderPath, map[string]string{auth.APIKeyHeader: "nope"}, "", http.StatusUnauthorized},
+		{"legacy order path", http.MethodGet, "/order/" + order.OrderUID, reader, "", http.StatusOK},
+		{"legacy order path with a browser Accept", http.MethodGet, "/order/" + order.OrderUID, with(reader, "Accept", "application/xml;q=0.9,*/*;q=0.8"), "", http.StatusOK},
+
+		{"create without scope", http.MethodPost, "/api/v1/orders", with(reader, "Content-Type", "application/json"), string(validOrder), http.StatusForbidden},
+		{"create malformed", http.MethodPost, "/api/v1/orders", writer, `{"order_uid":`, http.StatusBadRequest},
+		{"create invalid", http.MethodPost, "/api/v1/orders", writer, string(invalidOrder), http.StatusUnprocessableEntity},
+		{"create, database down", http.MethodPost, "/api/v1/orders", writer, string(validOrder), http.StatusServiceUnavailable},
+		{"bulk, database down", http.MethodPost, "/api/v1/orders:bulk", writer, "[" + string(validOrder) + "]", http.StatusServiceUnavailable},
+		{"bulk malformed", http.MethodPost, "/api/v1/orders:bulk", writer, `[{"order_uid":`, http.StatusBadRequest},
+
+		{"status malformed", http.MethodPatch, orderPath + "/status", writer, `{}`, http.StatusBadRequest},
+		{"status, database down", http.MethodPatch, orderPath + "/status", writer, `{"status":"paid"}`, http.StatusServiceUnavailable},
+		{"history, database down", http.MethodGet, orderPath + "/history", reader, "", http.StatusServiceUnavailable},
+
+		{"graphql post", http.MethodPost, "/api/v1/graphql", with(reader, "Content-Type", "application/json"),
+			`{"query":"{ order(uid: \"` + order.OrderUID + `\") { orderUid status items { name } } }"}`, http.StatusOK},
+		{"graphql get", http.MethodGet, "/api/v1/graphql?query=%7B%20order(uid%3A%20%22" + order.OrderUID + "%22)%20%7B%20orderUid%20%7D%20%7D", reader, "", http.StatusOK},
+		{"graphql without query", http.MethodPost, "/api/v1/graphql", with(reader, "Content-Type", "application/json"), `{}`, http.StatusBadRequest},
+
+		{"admin route as reader", http.MethodGet, "/api/v1/admin/replay", reader, "", http.StatusForbidden},
+		{"replays", http.MethodGet, "/api/v1/admin/replay", admin, "", http.StatusOK},
+		{"replay not found", http.MethodGet, "/api/v1/admin/replay/42", admin, "", http.StatusNotFound},
+		{"cancel unknown replay", http.MethodDelete, "/api/v1/admin/replay/42", admin, "", http.StatusNotFound},
+		{"replay without a start", http.MethodPost, "/api/v1/admin/replay", admin, `{"partition":0}`, http.StatusBadRequest},
+		{"consumer state", http.MethodGet, "/api/v1/admin/consumer", admin, "", http.StatusOK},
+		{"pause consumer", http.MethodPost, "/api/v1/admin/consumer/pause", admin, "", http.StatusOK},
+		{"resume consumer", http.MethodPost, "/api/v1/admin/consumer/resume", admin, "", http.StatusOK},
+		{"webhook without url", http.MethodPost, "/api/v1/admin/webhooks", admin, `{"event_types":["order.created"]}`, http.StatusBadRequest},
+		{"webhook with a bad id", http.MethodGet, "/api/v1/admin/webhooks/abc", admin, "", http.StatusBadRequest},
+		{"delete webhook with a bad id", http.MethodDelete, "/api/v1/admin/webhooks/abc", admin, "", http.StatusBadRequest},
+		{"deliveries with a bad id", http.MethodGet, "/api/v1/admin/webhooks/abc/deliveries", admin, "", http.StatusBadRequest},
+		{"export with a bad range", http.MethodGet, "/api/v1/admin/export?from=yesterday", admin, "", http.StatusBadRequest},
+		{"export, database down", http.MethodGet, "/api/v1/admin/export?from=2024-01-01T00:00:00Z", admin, "", http.StatusServiceUnavailable},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			checkAgainstSpec(t, spec, srv, tc)
+		})
+	}
+}
+
+// checkAgainstSpec sends the case's request through the handler and validates both the
+// request and the response against the spec.
+func checkAgainstSpec(t *testing.T, spec routers.Router, srv http.Handler, tc apiCase) {
+	t.Helper()
+	req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+	for k, v := range tc.header {
+		req.Header.Set(k, v)
+	}
+
+	route, pathParams, err := spec.FindRoute(req)
+	if err != nil {
+		t.Fatalf("%s %s is not in the spec: %v", tc.method, tc.path, err)
+	}
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+	}
+	// Requests expected to get 400 are malformed on purpose.
+	if tc.want != http.StatusBadRequest {
+		if err := openapi3filter.ValidateRequest(context.Background(), input); err != nil {
+			t.Fatalf("request does not match the spec: %v", err)
+		}
+		req.Body = io.NopCloser(strings.NewReader(tc.body))
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != tc.want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, tc.want, rec.Body)
+	}
+
+	resp := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 rec.Code,
+		Header:                 rec.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	if err := openapi3filter.ValidateResponse(context.Background(), resp); err != nil {
+		t.Fatalf("response does not match the spec: %v\nbody: %s", err, rec.Body)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"test-task/internal/kafka"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ReplayHandler struct {
+	replayer *kafka.Replayer
+}
+
+func NewReplayHandler(replayer *kafka.Replayer) *ReplayHandler {
+	return &ReplayHandler{replayer: replayer}
+}
+
+// StartReplay handles POST /admin/replay.
+func (h *ReplayHandler) StartReplay(w http.ResponseWriter, r *http.Request) {
+	var req kafka.ReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid replay request JSON: " + err.Error()})
+		return
+	}
+
+	progress, err := h.replayer.Start(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, kafka.ErrInvalidReplay) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		log.Printf("Failed to start replay: %v", err)
+		writeJSON(w, http.StatusBadGateway, errorResponse{Error: "Failed to start replay"})
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/admin/replay/"+progress.ID)
+	writeJSON(w, http.StatusAccepted, progress)
+}
+
+// ListReplays handles GET /admin/replay.
+func (h *ReplayHandler) ListReplays(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.replayer.List())
+}
+
+// GetReplay handles GET /admin/replay/{id}.
+func (h *ReplayHandler) GetReplay(w http.ResponseWriter, r *http.Request) {
+	progress, err := h.replayer.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "Replay not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
+
+// CancelReplay handles DELETE /admin/replay/{id}.
+func (h *ReplayHandler) CancelReplay(w http.ResponseWriter, r *http.Request) {
+	progress, err := h.replayer.Cancel(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "Replay not found"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, progress)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"test-task/internal/breaker"
+	"test-task/internal/repository"
+	"test-task/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type statusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// ChangeStatus handles PATCH /orders/{order_uid}/status.
+func (h *OrderHandler) ChangeStatus(w http.ResponseWriter, r *http.Request) {
+	orderUID := chi.URLParam(r, "order_uid")
+
+	var req statusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Status == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: `body must be a JSON object with a "status" field`})
+		return
+	}
+
+	change, err := h.service.ChangeStatus(r.Context(), orderUID, req.Status, req.Reason, service.SourceAPI)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, change)
+	case errors.Is(err, repository.ErrOrderNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "Order not found"})
+	case errors.Is(err, service.ErrUnknownStatus):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrStatusUnchanged):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	case errors.Is(err, breaker.ErrOpen):
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "Database unavailable"})
+	default:
+		log.Printf("Failed to change status of order %s: %v", orderUID, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Failed to change order status"})
+	}
+}
+
+// GetStatusHistory handles GET /orders/{order_uid}/history.
+func (h *OrderHandler) GetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	orderUID := chi.URLParam(r, "order_uid")
+
+	history, err := h.service.GetStatusHistory(r.Context(), orderUID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "Order not found"})
+			return
+		}
+		if errors.Is(err, breaker.ErrOpen) {
+			writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "Database unavailable"})
+			return
+		}
+		log.Printf("Failed to get status history of order %s: %v", orderUID, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Failed to get order history"})
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"test-task/internal/repository"
+	"test-task/internal/webhook"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxDeliveryLogLimit = 500
+
+type WebhookHandler struct {
+	repo *repository.WebhookRepository
+}
+
+func NewWebhookHandler(repo *repository.WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{repo: repo}
+}
+
+// CreateSubscription handles POST /admin/webhooks. The secret is generated when the body
+// does not carry one and is returned only in this response.
+func (h *WebhookHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var sub repository.WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid subscription JSON: " + err.Error()})
+		return
+	}
+	if u, err := url.Parse(sub.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "url must be an absolute http or https URL"})
+		return
+	}
+	if err := webhook.ValidateSubscription(sub); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		sub.Secret = hex.EncodeToString(secret)
+	}
+
+	if err := h.repo.CreateSubscription(r.Context(), &sub); err != nil {
+		log.Printf("Failed to create webhook subscription: %v", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Failed to create webhook subscription"})
+		return
+	}
+	w.Header().Set("Location", "/api/v1/admin/webhooks/"+strconv.FormatInt(sub.ID, 10))
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+// ListSubscriptions handles GET /admin/webhooks.
+func (h *WebhookHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.repo.ListSubscriptions(r.Context())
+	if err != nil {
+		log.Printf("Failed to list webhook subscriptions: %v", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Failed to list webhook subscriptions"})
+		return
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+// GetSubscription handles GET /admin/webhooks/{id}.
+func (h *WebhookHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	sub, err := h.repo.GetSubscription(r.Context(), id)
+	if err != nil {
+		h.subscriptionError(w, id, err)
+		return
+	}
+	sub.Secret = ""
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// DeleteSubscription handles DELETE /admin/webhooks/{id}.
+func (h *WebhookHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.repo.DeleteSubscription(r.Context(), id); err != nil {
+		h.subscriptionError(w, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries handles GET /admin/webhooks/{id}/deliveries?status=&limit=.
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.repo.GetSubscription(r.Context(), id); err != nil {
+		h.subscriptionError(w, id, err)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", repository.DeliveryPending, repository.DeliveryDelivered, repository.DeliveryFailed:
+	default:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "status must be pending, delivered or failed"})
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, maxDeliveryLogLimit)
+	}
+
+	deliveries, err := h.repo.ListDeliveries(r.Context(), id, status, limit)
+	if err != nil {
+		log.Printf("Failed to list deliveries of webhook %d: %v", id, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Failed to list webhook deliveries"})
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (h *WebhookHandler) subscriptionError(w http.ResponseWriter, id int64, err error) {
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "Webhook subscription not found"})
+		return
+	}
+	log.Printf("Failed to access webhook subscription %d: %v", id, err)
+	writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Failed to access webhook subscription"})
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "webhook id must be an integer"})
+		return 0, false
+	}
+	return id, true
+}
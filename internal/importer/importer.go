@@ -0,0 +1,108 @@
+// Package importer loads orders from JSONL, JSON array or CSV files through the same
+// validation and idempotent save path as the Kafka and HTTP ingest.
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"test-task/internal/model"
+	"test-task/internal/service"
+)
+
+type Summary struct {
+	Inserted  int `json:"inserted"`
+	Duplicate int `json:"duplicate"`
+	Rejected  int `json:"rejected"`
+}
+
+// Reject is a record that was not stored, as written to the rejects file.
+type Reject struct {
+	Position string               `json:"position"`
+	OrderUID string               `json:"order_uid,omitempty"`
+	Errors   []service.FieldError `json:"errors"`
+	Input    json.RawMessage      `json:"input"`
+}
+
+type Importer struct {
+	service   *service.OrderService
+	batchSize int
+}
+
+func NewImporter(service *service.OrderService, batchSize int) *Importer {
+	return &Importer{service: service, batchSize: max(batchSize, 1)}
+}
+
+// Run reads every record from src and stores the orders in batches of batchSize, one
+// transaction each. Orders that are already stored count as duplicates; records that do not
+// parse or validate, or that the database rejects, are passed to onReject. A read error or
+// a batch that fails as a whole stops the import; the summary then covers the batches
+// stored so far.
+func (im *Importer) Run(ctx context.Context, src Reader, onReject func(Reject) error) (Summary, error) {
+	var summary Summary
+	reject := func(rec Record, err error) error {
+		summary.Rejected++
+		r := Reject{Position: rec.Position, OrderUID: rec.Order.OrderUID, Input: rec.Input}
+		var verr *service.ValidationError
+		if errors.As(err, &verr) {
+			r.Errors = verr.Fields
+		} else {
+			r.Errors = []service.FieldError{{Message: err.Error()}}
+		}
+		return onReject(r)
+	}
+
+	batch := make([]Record, 0, im.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		orders := make([]model.Order, len(batch))
+		for i, rec := range batch {
+			orders[i] = rec.Order
+			// Tags are set through the rules engine, never taken from the file.
+			orders[i].Tags = nil
+		}
+		errs, err := im.service.ImportOrders(ctx, orders)
+		if err != nil {
+			return err
+		}
+		for i, err := range errs {
+			switch {
+			case err == nil:
+				summary.Inserted++
+			case errors.Is(err, service.ErrDuplicateOrder):
+				summary.Duplicate++
+			default:
+				if err := reject(batch[i], err); err != nil {
+					return err
+				}
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		rec, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return summary, err
+		}
+		if rec.Err != nil {
+			if err := reject(rec, rec.Err); err != nil {
+				return summary, err
+			}
+			continue
+		}
+		if batch = append(batch, rec); len(batch) == im.batchSize {
+			if err := flush(); err != nil {
+				return summary, err
+			}
+		}
+	}
+	return summary, flush()
+}
@@ -0,0 +1,359 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"test-task/internal/encoder"
+	"test-task/internal/model"
+	"test-task/internal/service"
+	"time"
+	"unicode"
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+const maxLineBytes = 4 << 20
+
+// Record is one order read from the input. Err is set when the input could not be turned
+// into an order; Input is the record as read, for the rejects file.
+type Record struct {
+	Position string
+	Input    json.RawMessage
+	Order    model.Order
+	Err      error
+}
+
+// Reader yields the records of an input one at a time. Next returns io.EOF after the last
+// record, and any other error when the input is too malformed to go on.
+type Reader interface {
+	Next() (Record, error)
+}
+
+// Mapping maps the canonical CSV columns (csvColumns) to the columns of an input file.
+// Columns it leaves out are looked up under their canonical name.
+type Mapping map[string]string
+
+// csvColumns are the columns of the CSV order representation the import reads. Tags are
+// left out: the rules engine sets them, so a tags column in the file is ignored.
+var csvColumns = slices.DeleteFunc(slices.Clone(encoder.CSVHeader), func(column string) bool {
+	return column == "tags"
+})
+
+func NewReader(r io.Reader, format string, mapping Mapping) (Reader, error) {
+	switch format {
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64<<10), maxLineBytes)
+		return &jsonlReader{scanner: scanner}, nil
+	case FormatJSON:
+		return newJSONReader(r)
+	case FormatCSV:
+		return newCSVReader(r, mapping)
+	}
+	return nil, fmt.Errorf("unknown format %q (use jsonl, json or csv)", format)
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (j *jsonlReader) Next() (Record, error) {
+	for j.scanner.Scan() {
+		j.line++
+		line := bytes.TrimSpace(j.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return decodeRecord(fmt.Sprintf("line %d", j.line), bytes.Clone(line)), nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("failed to read line %d: %w", j.line+1, err)
+	}
+	return Record{}, io.EOF
+}
+
+// jsonArrayReader reads a JSON array of orders, or a file holding a single order object
+// such as model.json.
+type jsonArrayReader struct {
+	dec    *json.Decoder
+	index  int
+	single bool
+}
+
+func newJSONReader(r io.Reader) (*jsonArrayReader, error) {
+	br := bufio.NewReader(r)
+	var first byte
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, errors.New("input must be a JSON array of orders or a single order")
+		}
+		if !unicode.IsSpace(rune(b)) {
+			first = b
+			break
+		}
+	}
+	if err := br.UnreadByte(); err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(br)
+	switch first {
+	case '[':
+		dec.Token()
+		return &jsonArrayReader{dec: dec}, nil
+	case '{':
+		return &jsonArrayReader{dec: dec, single: true}, nil
+	}
+	return nil, errors.New("input must be a JSON array of orders or a single order")
+}
+
+func (j *jsonArrayReader) Next() (Record, error) {
+	if j.single && j.index > 0 {
+		return Record{}, io.EOF
+	}
+	if !j.single && !j.dec.More() {
+		if _, err := j.dec.Token(); err != nil {
+			return Record{}, fmt.Errorf("malformed JSON array: %w", err)
+		}
+		return Record{}, io.EOF
+	}
+	var raw json.RawMessage
+	if err := j.dec.Decode(&raw); err != nil {
+		return Record{}, fmt.Errorf("malformed JSON at element %d: %w", j.index, err)
+	}
+	rec := decodeRecord(fmt.Sprintf("element %d", j.index), raw)
+	j.index++
+	return rec, nil
+}
+
+func decodeRecord(position string, data []byte) Record {
+	rec := Record{Position: position, Input: data}
+	if !json.Valid(data) {
+		rec.Input, _ = json.Marshal(string(data))
+	}
+	if err := json.Unmarshal(data, &rec.Order); err != nil {
+		rec.Err = invalid("", "invalid order JSON: "+err.Error())
+	}
+	return rec
+}
+
+// csvReader reads the layout of the CSV order representation: one row per item, with the
+// order columns repeated. Consecutive rows with the same order_uid make up one order.
+type csvReader struct {
+	r           *csv.Reader
+	columns     map[string]int
+	pending     [][]string
+	pendingLine int
+	done        bool
+}
+
+func newCSVReader(r io.Reader, mapping Mapping) (*csvReader, error) {
+	for canonical := range mapping {
+		if !slices.Contains(csvColumns, canonical) {
+			return nil, fmt.Errorf("mapping: unknown column %q", canonical)
+		}
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	source := make(map[string]int, len(header))
+	for i, name := range header {
+		source[strings.TrimSpace(name)] = i
+	}
+
+	columns := make(map[string]int, len(csvColumns))
+	for _, canonical := range csvColumns {
+		name := canonical
+		if mapped, ok := mapping[canonical]; ok {
+			name = mapped
+		}
+		if i, ok := source[name]; ok {
+			columns[canonical] = i
+		} else if _, ok := mapping[canonical]; ok {
+			return nil, fmt.Errorf("mapping: column %q for %s is not in the CSV header", name, canonical)
+		}
+	}
+	if _, ok := columns["order_uid"]; !ok {
+		return nil, errors.New("CSV has no order_uid column")
+	}
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (c *csvReader) Next() (Record, error) {
+	rows, first, last := c.pending, c.pendingLine, c.pendingLine
+	c.pending = nil
+	for !c.done {
+		row, err := c.r.Read()
+		if errors.Is(err, io.EOF) {
+			c.done = true
+			break
+		}
+		if err != nil {
+			return Record{}, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := c.r.FieldPos(0)
+		if len(rows) > 0 && c.get(row, "order_uid") != c.get(rows[0], "order_uid") {
+			c.pending, c.pendingLine = [][]string{row}, line
+			break
+		}
+		if len(rows) == 0 {
+			first = line
+		}
+		rows, last = append(rows, row), line
+	}
+	if len(rows) == 0 {
+		return Record{}, io.EOF
+	}
+
+	rec := Record{Position: fmt.Sprintf("rows %d-%d", first, last)}
+	if first == last {
+		rec.Position = fmt.Sprintf("row %d", first)
+	}
+	rec.Input, _ = json.Marshal(rows)
+	rec.Order, rec.Err = c.order(rows)
+	return rec, nil
+}
+
+func (c *csvReader) get(row []string, column string) string {
+	i, ok := c.columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// order builds the order from its rows: order, delivery and payment columns from the first
+// row, an item from each row that has any item column set.
+func (c *csvReader) order(rows [][]string) (model.Order, error) {
+	p := fieldParser{get: func(column string) string { return c.get(rows[0], column) }}
+	o := model.Order{
+		OrderUID:        p.str("order_uid"),
+		TrackNumber:     p.str("track_number"),
+		Entry:           p.str("entry"),
+		Locale:          p.str("locale"),
+		InternalSign:    p.str("internal_signature"),
+		CustomerID:      p.str("customer_id"),
+		DeliveryService: p.str("delivery_service"),
+		ShardKey:        p.str("shardkey"),
+		SmID:            p.int("sm_id"),
+		DateCreated:     p.time("date_created"),
+		OofShard:        p.str("oof_shard"),
+		Status:          p.str("status"),
+		Delivery: model.Delivery{
+			Name:    p.str("delivery_name"),
+			Phone:   p.str("delivery_phone"),
+			Zip:     p.str("delivery_zip"),
+			City:    p.str("delivery_city"),
+			Address: p.str("delivery_address"),
+			Region:  p.str("delivery_region"),
+			Email:   p.str("delivery_email"),
+		},
+		Payment: model.Payment{
+			Transaction:  p.str("payment_transaction"),
+			RequestID:    p.str("payment_request_id"),
+			Currency:     p.str("payment_currency"),
+			Provider:     p.str("payment_provider"),
+			Amount:       p.int("payment_amount"),
+			PaymentDT:    p.int64("payment_dt"),
+			Bank:         p.str("payment_bank"),
+			DeliveryCost: p.int("payment_delivery_cost"),
+			GoodsTotal:   p.int("payment_goods_total"),
+			CustomFee:    p.int("payment_custom_fee"),
+		},
+	}
+
+	for _, row := range rows {
+		if !c.hasItem(row) {
+			continue
+		}
+		p.get = func(column string) string { return c.get(row, column) }
+		o.Items = append(o.Items, model.Item{
+			ChrtID:      p.int64("item_chrt_id"),
+			TrackNumber: p.str("item_track_number"),
+			Price:       p.int("item_price"),
+			RID:         p.str("item_rid"),
+			Name:        p.str("item_name"),
+			Sale:        p.int("item_sale"),
+			Size:        p.str("item_size"),
+			TotalPrice:  p.int("item_total_price"),
+			NmID:        p.int64("item_nm_id"),
+			Brand:       p.str("item_brand"),
+			Status:      p.int("item_status"),
+		})
+	}
+
+	if len(p.errs) > 0 {
+		return o, &service.ValidationError{Fields: p.errs}
+	}
+	return o, nil
+}
+
+func (c *csvReader) hasItem(row []string) bool {
+	for _, column := range csvColumns {
+		if strings.HasPrefix(column, "item_") && c.get(row, column) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldParser converts CSV values, collecting an error per column that does not parse.
+// Empty values become zero values and are left to order validation.
+type fieldParser struct {
+	get  func(column string) string
+	errs []service.FieldError
+}
+
+func (p *fieldParser) str(column string) string {
+	return p.get(column)
+}
+
+func (p *fieldParser) int(column string) int {
+	return int(p.int64(column))
+}
+
+func (p *fieldParser) int64(column string) int64 {
+	v := p.get(column)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		p.errs = append(p.errs, service.FieldError{Field: column, Message: "must be an integer"})
+	}
+	return n
+}
+
+func (p *fieldParser) time(column string) time.Time {
+	v := p.get(column)
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		p.errs = append(p.errs, service.FieldError{Field: column, Message: "must be an RFC 3339 time"})
+	}
+	return t
+}
+
+func invalid(field, message string) error {
+	return &service.ValidationError{Fields: []service.FieldError{{Field: field, Message: message}}}
+}
@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"test-task/internal/config"
+	"test-task/internal/kafkaconn"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	DLQReasonHeader          = "dlq-reason"
+	DLQSourceTopicHeader     = "dlq-source-topic"
+	DLQSourcePartitionHeader = "dlq-source-partition"
+	DLQSourceOffsetHeader    = "dlq-source-offset"
+)
+
+// DeadLetterWriter forwards messages the service cannot process to the dead-letter topic,
+// keeping the original payload and headers and adding where the message came from and why.
+type DeadLetterWriter struct {
+	writer *kafka.Writer
+}
+
+func NewDeadLetterWriter(conn *kafkaconn.Connection) *DeadLetterWriter {
+	return &DeadLetterWriter{
+		writer: conn.Writer(config.KafkaDLQTopic()),
+	}
+}
+
+func (d *DeadLetterWriter) Send(ctx context.Context, m kafka.Message, reason string) error {
+	headers := make([]kafka.Header, 0, len(m.Headers)+4)
+	headers = append(headers, m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: DLQReasonHeader, Value: []byte(reason)},
+		kafka.Header{Key: DLQSourceTopicHeader, Value: []byte(m.Topic)},
+		kafka.Header{Key: DLQSourcePartitionHeader, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: DLQSourceOffsetHeader, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+	)
+
+	err := d.writer.WriteMessages(ctx, kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to write message to dead-letter topic: %w", ErrTransient, err)
+	}
+	log.Printf("Message %s/%d/%d sent to dead-letter topic: %s", m.Topic, m.Partition, m.Offset, reason)
+	return nil
+}
+
+func (d *DeadLetterWriter) Close() {
+	if err := d.writer.Close(); err != nil {
+		log.Printf("failed to close dead-letter writer: %v", err)
+	}
+}
@@ -2,69 +2,275 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log"
+	"strings"
+	"sync"
+	"test-task/internal/breaker"
 	"test-task/internal/config"
-	"test-task/internal/model"
-	"test-task/internal/service"
+	"test-task/internal/kafkaconn"
+	"test-task/internal/metrics"
+	"test-task/internal/repository"
+	"test-task/internal/tracing"
+	"time"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type KafkaSubscriber struct {
+var tracer = otel.Tracer("test-task/internal/kafka")
+
+// HandlerFunc processes one message of a topic. A returned error is logged and counted as
+// a failure. If it is retryable (see retryable) the message is held and handled again,
+// waiting for the database to recover if its breaker is open; any other error means the
+// message can never be processed, and it is committed like a processed one.
+type HandlerFunc func(ctx context.Context, m kafka.Message) error
+
+// ErrTransient marks a handler error caused by a dependency rather than by the message,
+// e.g. a failed dead-letter write or an unreachable schema registry.
+var ErrTransient = errors.New("kafka: transient failure")
+
+// Retries of a held message while the database breaker is closed back off from
+// retryMinBackoff, doubling up to retryMaxBackoff.
+const (
+	retryMinBackoff = 500 * time.Millisecond
+	retryMaxBackoff = 30 * time.Second
+)
+
+// retryable tells whether a message whose handler failed with err should be retried
+// rather than committed.
+func retryable(err error) bool {
+	return errors.Is(err, ErrTransient) || errors.Is(err, breaker.ErrOpen) || repository.IsDBFailure(err)
+}
+
+type consumer struct {
+	name    string
+	topic   string
 	reader  *kafka.Reader
-	service *service.OrderService
+	handler HandlerFunc
+	sub     *KafkaSubscriber
 }
 
-func NewKafkaSubscriber(service *service.OrderService) *KafkaSubscriber {
-	broker := config.KafkaBroker()
-	topic := config.KafkaTopic()
-	groupID := config.KafkaGroupID()
+// KafkaSubscriber routes every registered topic to its own handler. Each topic gets its
+// own reader with the consumer group settings from config.KafkaTopicConfig. Consumers
+// stop fetching while the database breaker is open or the subscriber is paused.
+type KafkaSubscriber struct {
+	conn      *kafkaconn.Connection
+	breaker   *breaker.Breaker
+	consumers []*consumer
+
+	mu      sync.Mutex
+	paused  bool
+	running chan struct{}
+}
+
+func NewKafkaSubscriber(conn *kafkaconn.Connection, dbBreaker *breaker.Breaker) *KafkaSubscriber {
+	running := make(chan struct{})
+	close(running)
+	return &KafkaSubscriber{conn: conn, breaker: dbBreaker, running: running}
+}
+
+// Handle registers handler for the topic configured under name in kafka.topics.
+func (ks *KafkaSubscriber) Handle(name string, handler HandlerFunc) {
+	tc := config.KafkaTopicConfig(name)
+	startOffset := kafka.FirstOffset
+	if tc.StartOffset == "last" {
+		startOffset = kafka.LastOffset
+	}
+
 	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{broker},
-		GroupID: groupID,
-		Topic:   topic,
+		Brokers:     ks.conn.Brokers,
+		GroupID:     tc.GroupID,
+		Topic:       tc.Topic,
+		Dialer:      ks.conn.Dialer,
+		StartOffset: startOffset,
+		MinBytes:    tc.MinBytes,
+		MaxBytes:    tc.MaxBytes,
+		MaxWait:     time.Duration(tc.MaxWaitMs) * time.Millisecond,
 	})
 
-	return &KafkaSubscriber{
+	ks.consumers = append(ks.consumers, &consumer{
+		name:    name,
+		topic:   tc.Topic,
 		reader:  r,
-		service: service,
-	}
+		handler: handler,
+		sub:     ks,
+	})
 }
 
+// Subscribe consumes all registered topics until ctx is cancelled.
 func (ks *KafkaSubscriber) Subscribe(ctx context.Context) {
-	log.Println("Subscribed to Kafka topic:", config.KafkaTopic())
+	var wg sync.WaitGroup
+	for _, c := range ks.consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Pause stops all consumers from fetching until Resume. A message being processed is
+// finished and committed.
+func (ks *KafkaSubscriber) Pause() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.paused {
+		return
+	}
+	log.Println("Kafka consumption paused")
+	ks.paused = true
+	ks.running = make(chan struct{})
+	metrics.KafkaConsumerPaused.Set(1)
+}
+
+func (ks *KafkaSubscriber) Resume() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if !ks.paused {
+		return
+	}
+	log.Println("Kafka consumption resumed")
+	ks.paused = false
+	close(ks.running)
+	metrics.KafkaConsumerPaused.Set(0)
+}
+
+func (ks *KafkaSubscriber) Paused() bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.paused
+}
+
+// wait blocks until consumption is neither paused nor held back by the breaker.
+func (ks *KafkaSubscriber) wait(ctx context.Context) error {
 	for {
+		ks.mu.Lock()
+		running := ks.running
+		ks.mu.Unlock()
+
 		select {
+		case <-running:
 		case <-ctx.Done():
-			log.Println("Stopping Kafka subscriber...")
+			return ctx.Err()
+		}
+		if err := ks.breaker.Wait(ctx); err != nil {
+			return err
+		}
+		if !ks.Paused() {
+			return nil
+		}
+	}
+}
+
+func (ks *KafkaSubscriber) Close() {
+	for _, c := range ks.consumers {
+		if err := c.reader.Close(); err != nil {
+			log.Printf("failed to close kafka reader for %s: %v", c.topic, err)
+		}
+	}
+}
+
+func (c *consumer) run(ctx context.Context) {
+	log.Println("Subscribed to Kafka topic:", c.topic)
+	for {
+		if err := c.sub.wait(ctx); err != nil {
+			log.Printf("Stopping Kafka subscriber for %s...", c.topic)
 			return
-		default:
-			m, err := ks.reader.FetchMessage(ctx)
-			if err != nil {
-				log.Printf("could not fetch message: %v", err)
-				continue
-			}
+		}
 
-			var order model.Order
-			if err := json.Unmarshal(m.Value, &order); err != nil {
-				log.Printf("Failed to unmarshal order: %v", err)
-				continue
-			}
+		m, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			log.Printf("could not fetch message from %s: %v", c.topic, err)
+			continue
+		}
 
-			ks.service.ProcessNewOrder(order)
+		if err := holdAndRetry(ctx, c.sub.breaker, m, func() error { return c.handle(ctx, m) }); err != nil {
+			log.Printf("Stopping Kafka subscriber for %s...", c.topic)
+			return
+		}
 
-			if err := ks.reader.CommitMessages(ctx, m); err != nil {
-				log.Printf("failed to commit messages: %v", err)
+		if err := c.reader.CommitMessages(ctx, m); err != nil {
+			log.Printf("failed to commit messages: %v", err)
+		}
+	}
+}
+
+// holdAndRetry runs handle for m until it succeeds or fails for good. While its error is
+// retryable the message is held: until the database breaker b closes if it is open,
+// otherwise for a growing backoff. It only returns an error when ctx is done.
+func holdAndRetry(ctx context.Context, b *breaker.Breaker, m kafka.Message, handle func() error) error {
+	backoff := retryMinBackoff
+	for {
+		err := handle()
+		if err != nil && ctx.Err() != nil {
+			// Failed because of shutdown: leave m uncommitted for the next run.
+			return ctx.Err()
+		}
+		if err == nil || !retryable(err) {
+			return nil
+		}
+
+		if b.State() == breaker.StateOpen {
+			log.Printf("Database unavailable, holding %s/%d/%d until it recovers", m.Topic, m.Partition, m.Offset)
+			if err := b.Wait(ctx); err != nil {
+				return err
 			}
+			backoff = retryMinBackoff
+			continue
+		}
+
+		log.Printf("Retrying %s/%d/%d in %s", m.Topic, m.Partition, m.Offset, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
 		}
+		backoff = min(2*backoff, retryMaxBackoff)
 	}
 }
 
-func (ks *KafkaSubscriber) Close() {
-	if ks.reader != nil {
-		if err := ks.reader.Close(); err != nil {
-			log.Printf("failed to close kafka reader: %v", err)
+func (c *consumer) handle(ctx context.Context, m kafka.Message) error {
+	msgCtx := otel.GetTextMapPropagator().Extract(ctx, tracing.NewHeaderCarrier(&m.Headers))
+	msgCtx, span := tracer.Start(msgCtx, m.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", m.Topic),
+			attribute.String("messaging.consumer.group.name", c.reader.Config().GroupID),
+			attribute.Int("messaging.kafka.partition", m.Partition),
+			attribute.Int64("messaging.kafka.offset", m.Offset),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	err := c.handler(msgCtx, m)
+	metrics.KafkaProcessingSeconds.WithLabelValues(c.topic).Observe(time.Since(start).Seconds())
+	if m.HighWaterMark > 0 {
+		metrics.KafkaConsumerLag.WithLabelValues(c.topic).Set(float64(m.HighWaterMark - m.Offset - 1))
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		metrics.KafkaMessages.WithLabelValues(c.topic, "failed").Inc()
+		log.Printf("Failed to handle message %s/%d/%d: %v", m.Topic, m.Partition, m.Offset, err)
+		return err
+	}
+	metrics.KafkaMessages.WithLabelValues(c.topic, "processed").Inc()
+	return nil
+}
+
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
 		}
 	}
+	return ""
 }
@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"test-task/internal/decoder"
+	"test-task/internal/model"
+	"test-task/internal/schemaregistry"
+	"test-task/internal/service"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OrderHandler decodes new orders, dead-letters the ones it cannot decode and passes the
+// rest to OrderService.ProcessNewOrder. A message that cannot be decoded because the schema
+// registry is unreachable is not malformed, so it is retried rather than dead-lettered.
+type OrderHandler struct {
+	service  *service.OrderService
+	decoders *decoder.Registry
+	dlq      *DeadLetterWriter
+}
+
+func NewOrderHandler(service *service.OrderService, decoders *decoder.Registry, dlq *DeadLetterWriter) *OrderHandler {
+	return &OrderHandler{
+		service:  service,
+		decoders: decoders,
+		dlq:      dlq,
+	}
+}
+
+func (h *OrderHandler) Handle(ctx context.Context, m kafka.Message) error {
+	order, err := decodeOrder(ctx, h.decoders, m)
+	if errors.Is(err, schemaregistry.ErrUnavailable) || (err != nil && ctx.Err() != nil) {
+		return fmt.Errorf("%w: %w", ErrTransient, err)
+	}
+	if err != nil {
+		return h.dlq.Send(ctx, m, err.Error())
+	}
+
+	err = h.service.ProcessNewOrder(ctx, order)
+	var verr *service.ValidationError
+	if errors.Is(err, service.ErrDuplicateOrder) || errors.As(err, &verr) {
+		return nil
+	}
+	return err
+}
+
+// decodeOrder picks the decoder from the message's content type and schema version.
+func decodeOrder(ctx context.Context, decoders *decoder.Registry, m kafka.Message) (model.Order, error) {
+	span := trace.SpanFromContext(ctx)
+
+	format := decoder.DetectFormat(headerValue(m.Headers, decoder.ContentTypeHeader), m.Value)
+	version := decoder.VersionOf(format, headerValue(m.Headers, decoder.SchemaVersionHeader), m.Value)
+	span.SetAttributes(
+		attribute.String("order.content_type", format),
+		attribute.String("order.schema_version", version),
+	)
+
+	order, err := decoders.Decode(ctx, format, version, m.Value)
+	if err != nil {
+		span.RecordError(err)
+		log.Printf("Failed to decode order (%s, schema version %q): %v", format, version, err)
+	}
+	return order, err
+}
@@ -0,0 +1,366 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"test-task/internal/breaker"
+	"test-task/internal/config"
+	"test-task/internal/decoder"
+	"test-task/internal/kafkaconn"
+	"test-task/internal/schemaregistry"
+	"test-task/internal/service"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ReplayRunning   = "running"
+	ReplayCompleted = "completed"
+	ReplayFailed    = "failed"
+	ReplayCancelled = "cancelled"
+)
+
+var (
+	ErrInvalidReplay  = errors.New("invalid replay request")
+	ErrReplayNotFound = errors.New("replay not found")
+)
+
+// finishedReplayTTL is how long a finished replay stays listed before it is forgotten.
+const finishedReplayTTL = 24 * time.Hour
+
+// ReplayRequest selects a window of one partition of the orders topic. The start is
+// either an offset or a timestamp; the end is an inclusive offset, an exclusive timestamp
+// or, if neither is set, the partition's high-water mark at the time the replay starts.
+type ReplayRequest struct {
+	Partition  int        `json:"partition"`
+	FromOffset *int64     `json:"from_offset,omitempty"`
+	FromTime   *time.Time `json:"from_time,omitempty"`
+	ToOffset   *int64     `json:"to_offset,omitempty"`
+	ToTime     *time.Time `json:"to_time,omitempty"`
+}
+
+type ReplayProgress struct {
+	ID          string     `json:"id"`
+	State       string     `json:"state"`
+	Topic       string     `json:"topic"`
+	Partition   int        `json:"partition"`
+	StartOffset int64      `json:"start_offset"`
+	EndOffset   int64      `json:"end_offset"`
+	Offset      int64      `json:"offset"`
+	Processed   int        `json:"processed"`
+	Created     int        `json:"created"`
+	Duplicate   int        `json:"duplicate"`
+	Invalid     int        `json:"invalid"`
+	Undecodable int        `json:"undecodable"`
+	Failed      int        `json:"failed"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type replayJob struct {
+	mu       sync.Mutex
+	progress ReplayProgress
+	cancel   context.CancelFunc
+}
+
+// Replayer re-reads a window of the orders topic with a partition reader outside any
+// consumer group, so the live group's committed offsets are never touched. Orders go
+// through OrderService.ProcessNewOrder; already stored ones are counted as duplicates.
+// Like the live consumers, a replay holds a message the database or schema registry could
+// not take and waits for the database breaker to close rather than counting it as failed.
+type Replayer struct {
+	conn     *kafkaconn.Connection
+	topic    string
+	service  *service.OrderService
+	decoders *decoder.Registry
+	breaker  *breaker.Breaker
+
+	mu   sync.Mutex
+	seq  int
+	jobs map[string]*replayJob
+}
+
+func NewReplayer(conn *kafkaconn.Connection, service *service.OrderService, decoders *decoder.Registry, dbBreaker *breaker.Breaker) *Replayer {
+	return &Replayer{
+		conn:     conn,
+		topic:    config.KafkaTopic(),
+		service:  service,
+		decoders: decoders,
+		breaker:  dbBreaker,
+		jobs:     make(map[string]*replayJob),
+	}
+}
+
+// Start resolves the window and replays it in the background. The replay outlives ctx
+// and stops on Cancel or Close.
+func (rp *Replayer) Start(ctx context.Context, req ReplayRequest) (ReplayProgress, error) {
+	if (req.FromOffset == nil) == (req.FromTime == nil) {
+		return ReplayProgress{}, fmt.Errorf("%w: exactly one of from_offset and from_time is required", ErrInvalidReplay)
+	}
+	if req.ToOffset != nil && req.ToTime != nil {
+		return ReplayProgress{}, fmt.Errorf("%w: to_offset and to_time are mutually exclusive", ErrInvalidReplay)
+	}
+	if req.Partition < 0 {
+		return ReplayProgress{}, fmt.Errorf("%w: partition must not be negative", ErrInvalidReplay)
+	}
+
+	start, end, err := rp.bounds(ctx, req)
+	if err != nil {
+		return ReplayProgress{}, err
+	}
+
+	rp.mu.Lock()
+	rp.evictFinished()
+	rp.seq++
+	id := strconv.Itoa(rp.seq)
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	job := &replayJob{
+		progress: ReplayProgress{
+			ID:          id,
+			State:       ReplayRunning,
+			Topic:       rp.topic,
+			Partition:   req.Partition,
+			StartOffset: start,
+			EndOffset:   end,
+			Offset:      start,
+			StartedAt:   time.Now().UTC(),
+		},
+		cancel: cancel,
+	}
+	rp.jobs[id] = job
+	rp.mu.Unlock()
+
+	log.Printf("Replay %s started: %s/%d offsets [%d, %d)", id, rp.topic, req.Partition, start, end)
+	go rp.run(jobCtx, job)
+
+	return job.snapshot(), nil
+}
+
+func (rp *Replayer) Get(id string) (ReplayProgress, error) {
+	rp.mu.Lock()
+	rp.evictFinished()
+	job, ok := rp.jobs[id]
+	rp.mu.Unlock()
+	if !ok {
+		return ReplayProgress{}, ErrReplayNotFound
+	}
+	return job.snapshot(), nil
+}
+
+func (rp *Replayer) List() []ReplayProgress {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.evictFinished()
+
+	out := make([]ReplayProgress, 0, len(rp.jobs))
+	for _, job := range rp.jobs {
+		out = append(out, job.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+func (rp *Replayer) Cancel(id string) (ReplayProgress, error) {
+	rp.mu.Lock()
+	job, ok := rp.jobs[id]
+	rp.mu.Unlock()
+	if !ok {
+		return ReplayProgress{}, ErrReplayNotFound
+	}
+	job.cancel()
+	return job.snapshot(), nil
+}
+
+// evictFinished forgets replays that finished more than finishedReplayTTL ago. rp.mu must be held.
+func (rp *Replayer) evictFinished() {
+	for id, job := range rp.jobs {
+		if p := job.snapshot(); p.FinishedAt != nil && time.Since(*p.FinishedAt) > finishedReplayTTL {
+			delete(rp.jobs, id)
+		}
+	}
+}
+
+// Close cancels every running replay.
+func (rp *Replayer) Close() {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	for _, job := range rp.jobs {
+		job.cancel()
+	}
+}
+
+// bounds turns the request into a half-open offset range [start, end) clamped to what
+// the partition currently holds.
+func (rp *Replayer) bounds(ctx context.Context, req ReplayRequest) (int64, int64, error) {
+	lc, err := rp.conn.Dialer.DialLeader(ctx, "tcp", rp.conn.Brokers[0], rp.topic, req.Partition)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to connect to partition leader: %w", err)
+	}
+	defer lc.Close()
+
+	first, last, err := lc.ReadOffsets()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read partition offsets: %w", err)
+	}
+
+	offsetAt := func(t time.Time) (int64, error) {
+		offset, err := lc.ReadOffset(t)
+		if err != nil {
+			return 0, fmt.Errorf("failed to look up offset for %s: %w", t.Format(time.RFC3339), err)
+		}
+		if offset < 0 {
+			return last, nil
+		}
+		return offset, nil
+	}
+
+	start := first
+	if req.FromOffset != nil {
+		start = *req.FromOffset
+	} else if start, err = offsetAt(*req.FromTime); err != nil {
+		return 0, 0, err
+	}
+
+	end := last
+	switch {
+	case req.ToOffset != nil:
+		end = *req.ToOffset + 1
+	case req.ToTime != nil:
+		if end, err = offsetAt(*req.ToTime); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	start = max(start, first)
+	end = min(end, last)
+	if start > end {
+		return 0, 0, fmt.Errorf("%w: empty range [%d, %d)", ErrInvalidReplay, start, end)
+	}
+	return start, end, nil
+}
+
+func (rp *Replayer) run(ctx context.Context, job *replayJob) {
+	p := job.snapshot()
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   rp.conn.Brokers,
+		Topic:     p.Topic,
+		Partition: p.Partition,
+		Dialer:    rp.conn.Dialer,
+	})
+	defer reader.Close()
+
+	err := reader.SetOffset(p.StartOffset)
+	for offset := p.StartOffset; err == nil && offset < p.EndOffset; {
+		var m kafka.Message
+		m, err = reader.ReadMessage(ctx)
+		if err != nil {
+			break
+		}
+		var result string
+		err = holdAndRetry(ctx, rp.breaker, m, func() (err error) {
+			result, err = rp.process(ctx, m)
+			return err
+		})
+		if err != nil {
+			break
+		}
+		offset = m.Offset + 1
+		job.record(offset, result)
+	}
+
+	job.finish(err)
+	p = job.snapshot()
+	log.Printf("Replay %s %s at offset %d: %d processed, %d created, %d duplicate, %d invalid, %d undecodable, %d failed",
+		p.ID, p.State, p.Offset, p.Processed, p.Created, p.Duplicate, p.Invalid, p.Undecodable, p.Failed)
+}
+
+// process replays one message and returns how it ended. A non-nil error is retryable and
+// means the message has to be processed again.
+func (rp *Replayer) process(ctx context.Context, m kafka.Message) (string, error) {
+	ctx, span := tracer.Start(ctx, m.Topic+" replay",
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", m.Topic),
+			attribute.Int("messaging.kafka.partition", m.Partition),
+			attribute.Int64("messaging.kafka.offset", m.Offset),
+		),
+	)
+	defer span.End()
+
+	order, err := decodeOrder(ctx, rp.decoders, m)
+	if errors.Is(err, schemaregistry.ErrUnavailable) || (err != nil && ctx.Err() != nil) {
+		return "", fmt.Errorf("%w: %w", ErrTransient, err)
+	}
+	if err != nil {
+		return "undecodable", nil
+	}
+
+	err = rp.service.ProcessNewOrder(ctx, order)
+	var verr *service.ValidationError
+	switch {
+	case err == nil:
+		return "created", nil
+	case errors.Is(err, service.ErrDuplicateOrder):
+		return "duplicate", nil
+	case errors.As(err, &verr):
+		return "invalid", nil
+	case retryable(err) || ctx.Err() != nil:
+		return "", err
+	default:
+		log.Printf("Replay failed to process order %s at offset %d: %v", order.OrderUID, m.Offset, err)
+		return "failed", nil
+	}
+}
+
+func (j *replayJob) snapshot() ReplayProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress
+}
+
+func (j *replayJob) record(offset int64, result string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.progress.Offset = offset
+	j.progress.Processed++
+	switch result {
+	case "created":
+		j.progress.Created++
+	case "duplicate":
+		j.progress.Duplicate++
+	case "invalid":
+		j.progress.Invalid++
+	case "undecodable":
+		j.progress.Undecodable++
+	default:
+		j.progress.Failed++
+	}
+}
+
+func (j *replayJob) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now().UTC()
+	j.progress.FinishedAt = &now
+	switch {
+	case err == nil:
+		j.progress.State = ReplayCompleted
+	case errors.Is(err, context.Canceled):
+		j.progress.State = ReplayCancelled
+	default:
+		j.progress.State = ReplayFailed
+		j.progress.Error = err.Error()
+	}
+	j.cancel()
+}
@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"test-task/internal/model"
+	"test-task/internal/service"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type StatusEvent struct {
+	OrderUID string `json:"order_uid"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason"`
+}
+
+type CancellationEvent struct {
+	OrderUID string `json:"order_uid"`
+	Reason   string `json:"reason"`
+}
+
+// StatusHandler applies order status-update events.
+type StatusHandler struct {
+	service *service.OrderService
+}
+
+func NewStatusHandler(service *service.OrderService) *StatusHandler {
+	return &StatusHandler{service: service}
+}
+
+func (h *StatusHandler) Handle(ctx context.Context, m kafka.Message) error {
+	var event StatusEvent
+	if err := json.Unmarshal(m.Value, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal status event: %w", err)
+	}
+	return changeStatus(ctx, h.service, event.OrderUID, event.Status, event.Reason)
+}
+
+// CancellationHandler cancels orders named in cancellation events.
+type CancellationHandler struct {
+	service *service.OrderService
+}
+
+func NewCancellationHandler(service *service.OrderService) *CancellationHandler {
+	return &CancellationHandler{service: service}
+}
+
+func (h *CancellationHandler) Handle(ctx context.Context, m kafka.Message) error {
+	var event CancellationEvent
+	if err := json.Unmarshal(m.Value, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal cancellation event: %w", err)
+	}
+	return changeStatus(ctx, h.service, event.OrderUID, model.StatusCancelled, event.Reason)
+}
+
+func changeStatus(ctx context.Context, svc *service.OrderService, uid, status, reason string) error {
+	_, err := svc.ChangeStatus(ctx, uid, status, reason, service.SourceKafka)
+	if err != nil && !errors.Is(err, service.ErrStatusUnchanged) {
+		return fmt.Errorf("failed to apply status %q to order %s: %w", status, uid, err)
+	}
+	return nil
+}
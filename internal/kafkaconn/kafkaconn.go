@@ -0,0 +1,118 @@
+package kafkaconn
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"test-task/internal/config"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Connection holds the broker list and the TLS/SASL settings shared by every reader
+// and writer talking to one Kafka cluster.
+type Connection struct {
+	Brokers   []string
+	Dialer    *kafka.Dialer
+	Transport *kafka.Transport
+}
+
+func New(brokers []string, sec config.KafkaSecurityConf) (*Connection, error) {
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("kafkaconn: no brokers configured")
+	}
+
+	tlsCfg, err := tlsConfig(sec.TLS)
+	if err != nil {
+		return nil, err
+	}
+	mech, err := mechanism(sec.SASL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Connection{
+		Brokers: brokers,
+		Dialer: &kafka.Dialer{
+			Timeout:       10 * time.Second,
+			DualStack:     true,
+			TLS:           tlsCfg,
+			SASLMechanism: mech,
+		},
+		Transport: &kafka.Transport{
+			TLS:  tlsCfg,
+			SASL: mech,
+		},
+	}, nil
+}
+
+// FromConfig builds the connection used by the service: reader, status reader and DLQ writer.
+func FromConfig() (*Connection, error) {
+	return New(config.KafkaBrokers(), config.KafkaSecurity())
+}
+
+// Writer returns a writer for topic that shares the connection's brokers and transport.
+func (c *Connection) Writer(topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(c.Brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.LeastBytes{},
+		Transport:              c.Transport,
+		AllowAutoTopicCreation: true,
+	}
+}
+
+func tlsConfig(conf config.TLSConf) (*tls.Config, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafkaconn: could not read CA file %s: %w", conf.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kafkaconn: no certificates found in CA file %s", conf.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafkaconn: could not load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+func mechanism(conf config.SASLConf) (sasl.Mechanism, error) {
+	switch strings.ToUpper(conf.Mechanism) {
+	case "", "NONE":
+		return nil, nil
+	case "PLAIN":
+		return plain.Mechanism{Username: conf.Username, Password: conf.Password}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, conf.Username, conf.Password)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, conf.Username, conf.Password)
+	default:
+		return nil, fmt.Errorf("kafkaconn: unsupported SASL mechanism %q", conf.Mechanism)
+	}
+}
@@ -0,0 +1,100 @@
+package masking
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"test-task/internal/config"
+	"test-task/internal/model"
+)
+
+const (
+	StrategyPartial = "partial"
+	StrategyEmail   = "email"
+	StrategyFull    = "full"
+	StrategyNone    = "none"
+)
+
+const (
+	FieldDeliveryName       = "delivery.name"
+	FieldDeliveryPhone      = "delivery.phone"
+	FieldDeliveryEmail      = "delivery.email"
+	FieldDeliveryAddress    = "delivery.address"
+	FieldPaymentTransaction = "payment.transaction"
+	maskChar                = '*'
+	fullMaskLen             = 6
+)
+
+// Fields lists the order fields that can be masked.
+var Fields = []string{
+	FieldDeliveryName,
+	FieldDeliveryPhone,
+	FieldDeliveryEmail,
+	FieldDeliveryAddress,
+	FieldPaymentTransaction,
+}
+
+type Masker struct {
+	rules map[string]config.MaskRule
+}
+
+func New(rules map[string]config.MaskRule) (*Masker, error) {
+	for field, rule := range rules {
+		if !slices.Contains(Fields, field) {
+			return nil, fmt.Errorf("masking: unknown field %q (use %s)", field, strings.Join(Fields, ", "))
+		}
+		switch rule.Strategy {
+		case StrategyPartial, StrategyEmail, StrategyFull, StrategyNone:
+		default:
+			return nil, fmt.Errorf("masking: unknown strategy %q for field %s", rule.Strategy, field)
+		}
+	}
+	return &Masker{rules: rules}, nil
+}
+
+func (m *Masker) MaskOrder(order model.Order) model.Order {
+	order.Delivery.Name = m.mask(FieldDeliveryName, order.Delivery.Name)
+	order.Delivery.Phone = m.mask(FieldDeliveryPhone, order.Delivery.Phone)
+	order.Delivery.Email = m.mask(FieldDeliveryEmail, order.Delivery.Email)
+	order.Delivery.Address = m.mask(FieldDeliveryAddress, order.Delivery.Address)
+	order.Payment.Transaction = m.mask(FieldPaymentTransaction, order.Payment.Transaction)
+	return order
+}
+
+func (m *Masker) mask(field, value string) string {
+	rule, ok := m.rules[field]
+	if !ok || value == "" {
+		return value
+	}
+	switch rule.Strategy {
+	case StrategyPartial:
+		return partial(value, rule.KeepStart, rule.KeepEnd)
+	case StrategyEmail:
+		local, domain, found := strings.Cut(value, "@")
+		if !found {
+			return partial(value, rule.KeepStart, rule.KeepEnd)
+		}
+		return partial(local, max(rule.KeepStart, 1), 0) + "@" + domain
+	case StrategyFull:
+		return strings.Repeat(string(maskChar), fullMaskLen)
+	default:
+		return value
+	}
+}
+
+// partial keeps keepStart leading and keepEnd trailing runes, e.g. "+97250123400" -> "+972******00".
+// At least a third of the value is always hidden, so short values never leak through.
+func partial(value string, keepStart, keepEnd int) string {
+	runes := []rune(value)
+	visible := len(runes) - (len(runes)+2)/3
+	if keepStart > visible {
+		keepStart = visible
+	}
+	if keepEnd > visible-keepStart {
+		keepEnd = visible - keepStart
+	}
+	for i := keepStart; i < len(runes)-keepEnd; i++ {
+		runes[i] = maskChar
+	}
+	return string(runes)
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "order_service"
+
+var (
+	RateLimitAllowed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ratelimit",
+		Name:      "allowed_total",
+		Help:      "Requests let through by the rate limiter.",
+	}, []string{"route"})
+
+	RateLimitRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ratelimit",
+		Name:      "rejected_total",
+		Help:      "Requests rejected with 429 by the rate limiter.",
+	}, []string{"route"})
+
+	RateLimitClients = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "ratelimit",
+		Name:      "tracked_clients",
+		Help:      "Clients with an active token bucket.",
+	}, []string{"route"})
+
+	RuleViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rules",
+		Name:      "violations_total",
+		Help:      "Orders violating a business rule, by rule and configured action.",
+	}, []string{"rule", "action"})
+
+	KafkaMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_total",
+		Help:      "Consumed Kafka messages by topic and result.",
+	}, []string{"topic", "result"})
+
+	KafkaProcessingSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "processing_seconds",
+		Help:      "Time spent in the topic handler per message.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages behind the partition high-water mark, as of the last consumed message.",
+	}, []string{"topic"})
+
+	KafkaConsumerPaused = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_paused",
+		Help:      "1 while consumption is paused through the admin API.",
+	})
+
+	OutboxPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "published_total",
+		Help:      "Outbox events published to Kafka.",
+	})
+
+	OutboxFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "failures_total",
+		Help:      "Failed attempts to publish an outbox batch.",
+	})
+
+	FeedClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "feed",
+		Name:      "clients",
+		Help:      "Connected live feed clients (SSE and WebSocket).",
+	})
+
+	FeedDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "feed",
+		Name:      "dropped_clients_total",
+		Help:      "Live feed clients disconnected for falling behind.",
+	})
+
+	CircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "circuit",
+		Name:      "open",
+		Help:      "1 while the circuit breaker is open.",
+	}, []string{"name"})
+
+	CircuitTrips = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "circuit",
+		Name:      "trips_total",
+		Help:      "Times the circuit breaker opened.",
+	}, []string{"name"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -3,55 +3,132 @@ package model
 import "time"
 
 type Delivery struct {
-	Name    string `json:"name" fake:"{name}" validate:"required"`
-	Phone   string `json:"phone" fake:"{phone}" validate:"required"`
-	Zip     string `json:"zip" fake:"{zip}" validate:"required"`
-	City    string `json:"city" fake:"{city}" validate:"required"`
-	Address string `json:"address" fake:"{street}" validate:"required"`
-	Region  string `json:"region" fake:"{state}" validate:"required"`
-	Email   string `json:"email" fake:"{email}" validate:"required,email"`
+	Name    string `json:"name" xml:"name" fake:"{name}" validate:"required"`
+	Phone   string `json:"phone" xml:"phone" fake:"{phone}" validate:"required"`
+	Zip     string `json:"zip" xml:"zip" fake:"{zip}" validate:"required"`
+	City    string `json:"city" xml:"city" fake:"{city}" validate:"required"`
+	Address string `json:"address" xml:"address" fake:"{street}" validate:"required"`
+	Region  string `json:"region" xml:"region" fake:"{state}" validate:"required"`
+	Email   string `json:"email" xml:"email" fake:"{email}" validate:"required,email"`
 }
 
 type Payment struct {
-	Transaction  string `json:"transaction" fake:"{regex:[a-z1-9]{10}}" validate:"required"`
-	RequestID    string `json:"request_id,omitempty" fake:"{regex:[a-z1-9]{9}}" validate:"required"`
-	Currency     string `json:"currency" fake:"{regex:[A-Z]{3}}" validate:"required"`
-	Provider     string `json:"provider" fake:"{company}" validate:"required"`
-	Amount       int    `json:"amount" fake:"{number:100,3000}" validate:"required,gt=0"`
-	PaymentDT    int64  `json:"payment_dt" fake:"{number:10000000,99999999}" validate:"required,gt=0"`
-	Bank         string `json:"bank" fake:"{company}" validate:"required"`
-	DeliveryCost int    `json:"delivery_cost" fake:"{number:100,1000}" validate:"gte=0"`
-	GoodsTotal   int    `json:"goods_total" fake:"{number:50,500}" validate:"gte=0"`
-	CustomFee    int    `json:"custom_fee" fake:"{number:0,50}" validate:"gte=0"`
+	Transaction  string `json:"transaction" xml:"transaction" fake:"{regex:[a-z1-9]{10}}" validate:"required"`
+	RequestID    string `json:"request_id,omitempty" xml:"request_id,omitempty" fake:"{regex:[a-z1-9]{9}}"`
+	Currency     string `json:"currency" xml:"currency" fake:"{regex:[A-Z]{3}}" validate:"required"`
+	Provider     string `json:"provider" xml:"provider" fake:"{company}" validate:"required"`
+	Amount       int    `json:"amount" xml:"amount" fake:"{number:100,3000}" validate:"required,gt=0"`
+	PaymentDT    int64  `json:"payment_dt" xml:"payment_dt" fake:"{number:10000000,99999999}" validate:"required,gt=0"`
+	Bank         string `json:"bank" xml:"bank" fake:"{company}" validate:"required"`
+	DeliveryCost int    `json:"delivery_cost" xml:"delivery_cost" fake:"{number:100,1000}" validate:"gte=0"`
+	GoodsTotal   int    `json:"goods_total" xml:"goods_total" fake:"{number:50,500}" validate:"gte=0"`
+	CustomFee    int    `json:"custom_fee" xml:"custom_fee" fake:"{number:0,50}" validate:"gte=0"`
 }
 
 type Item struct {
-	ChrtID      int64  `json:"chrt_id" fake:"{number:1000000,9999999}" validate:"required,gt=0"`
-	TrackNumber string `json:"track_number" fake:"{regex:[A-Z]{14}}" validate:"required"`
-	Price       int    `json:"price" fake:"{number:100,1000}" validate:"required,gt=0"`
-	RID         string `json:"rid" fake:"{regex:[a-z1-9]{19}}" validate:"required"`
-	Name        string `json:"name" fake:"{productname}" validate:"required"`
-	Sale        int    `json:"sale" fake:"{number:0,100}" validate:"gte=0,lte=100"`
-	Size        string `json:"size" fake:"{regex:[0-9]{2}}" validate:"required"`
-	TotalPrice  int    `json:"total_price" fake:"{number:100,1200}" validate:"gte=0"`
-	NmID        int64  `json:"nm_id" fake:"{number:1000000,9999999}" validate:"required,gt=0"`
-	Brand       string `json:"brand" fake:"{company}" validate:"required"`
-	Status      int    `json:"status" fake:"{number:100,299}" validate:"gte=0"`
+	ChrtID      int64  `json:"chrt_id" xml:"chrt_id" fake:"{number:1000000,9999999}" validate:"required,gt=0"`
+	TrackNumber string `json:"track_number" xml:"track_number" fake:"{regex:[A-Z]{14}}" validate:"required"`
+	Price       int    `json:"price" xml:"price" fake:"{number:100,1000}" validate:"required,gt=0"`
+	RID         string `json:"rid" xml:"rid" fake:"{regex:[a-z1-9]{19}}" validate:"required"`
+	Name        string `json:"name" xml:"name" fake:"{productname}" validate:"required"`
+	Sale        int    `json:"sale" xml:"sale" fake:"{number:0,100}" validate:"gte=0,lte=100"`
+	Size        string `json:"size" xml:"size" fake:"{regex:[0-9]{2}}" validate:"required"`
+	TotalPrice  int    `json:"total_price" xml:"total_price" fake:"{number:100,1200}" validate:"gte=0"`
+	NmID        int64  `json:"nm_id" xml:"nm_id" fake:"{number:1000000,9999999}" validate:"required,gt=0"`
+	Brand       string `json:"brand" xml:"brand" fake:"{company}" validate:"required"`
+	Status      int    `json:"status" xml:"status" fake:"{number:100,299}" validate:"gte=0"`
 }
 
 type Order struct {
-	OrderUID        string    `json:"order_uid" fake:"{regex:[a-z1-9]{19}}" validate:"required"`
-	TrackNumber     string    `json:"track_number" fake:"{regex:[A-Z]{14}}" validate:"required"`
-	Entry           string    `json:"entry" fake:"{regex:[A-Z]{4}}" validate:"required"`
-	Delivery        Delivery  `json:"delivery" validate:"required"`
-	Payment         Payment   `json:"payment"  validate:"required"`
-	Items           []Item    `json:"items" fakesize:"1,3" validate:"required,dive"`
-	Locale          string    `json:"locale" fake:"{regex:[A-Z]{3}}" validate:"required"`
-	InternalSign    string    `json:"internal_signature" fake:"{regex:[a-z1-9]{10}}" validate:"required"`
-	CustomerID      string    `json:"customer_id" fake:"{username}" validate:"required"`
-	DeliveryService string    `json:"delivery_service" fake:"{word}" validate:"required"`
-	ShardKey        string    `json:"shardkey" fake:"{digit}{digit}" validate:"required"`
-	SmID            int       `json:"sm_id" fake:"{number:1,100}" validate:"required,gt=0"`
-	DateCreated     time.Time `json:"date_created" fake:"{date}" validate:"required"`
-	OofShard        string    `json:"oof_shard" fake:"{digit}" validate:"required"`
+	OrderUID        string    `json:"order_uid" xml:"order_uid" fake:"{regex:[a-z1-9]{19}}" validate:"required"`
+	TrackNumber     string    `json:"track_number" xml:"track_number" fake:"{regex:[A-Z]{14}}" validate:"required"`
+	Entry           string    `json:"entry" xml:"entry" fake:"{regex:[A-Z]{4}}" validate:"required"`
+	Delivery        Delivery  `json:"delivery" xml:"delivery" validate:"required"`
+	Payment         Payment   `json:"payment" xml:"payment"  validate:"required"`
+	Items           []Item    `json:"items" xml:"items>item" fakesize:"1,3" validate:"required,dive"`
+	Locale          string    `json:"locale" xml:"locale" fake:"{regex:[A-Z]{3}}" validate:"required"`
+	InternalSign    string    `json:"internal_signature" xml:"internal_signature" fake:"{regex:[a-z1-9]{10}}"`
+	CustomerID      string    `json:"customer_id" xml:"customer_id" fake:"{username}" validate:"required"`
+	DeliveryService string    `json:"delivery_service" xml:"delivery_service" fake:"{word}" validate:"required"`
+	ShardKey        string    `json:"shardkey" xml:"shardkey" fake:"{digit}{digit}" validate:"required"`
+	SmID            int       `json:"sm_id" xml:"sm_id" fake:"{number:1,100}" validate:"required,gt=0"`
+	DateCreated     time.Time `json:"date_created" xml:"date_created" fake:"{date}" validate:"required"`
+	OofShard        string    `json:"oof_shard" xml:"oof_shard" fake:"{digit}" validate:"required"`
+	Status          string    `json:"status,omitempty" xml:"status,omitempty" fake:"skip" validate:"omitempty,oneof=created paid shipped delivered cancelled"`
+	Tags            []string  `json:"tags,omitempty" xml:"tags>tag,omitempty" fake:"skip"`
+	// UpdatedAt is when the stored order last changed. It backs Last-Modified and is not
+	// part of the order's representation.
+	UpdatedAt time.Time `json:"-" xml:"-" fake:"skip"`
+}
+
+const (
+	StatusCreated   = "created"
+	StatusPaid      = "paid"
+	StatusShipped   = "shipped"
+	StatusDelivered = "delivered"
+	StatusCancelled = "cancelled"
+)
+
+type StatusChange struct {
+	OrderUID   string    `json:"order_uid"`
+	FromStatus string    `json:"from_status,omitempty"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason,omitempty"`
+	Source     string    `json:"source"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+const EventOrderStored = "order.stored"
+
+// OrderStoredEvent is published once an order has been committed to the database.
+type OrderStoredEvent struct {
+	Type            string    `json:"type"`
+	OrderUID        string    `json:"order_uid"`
+	TrackNumber     string    `json:"track_number"`
+	CustomerID      string    `json:"customer_id"`
+	DeliveryService string    `json:"delivery_service"`
+	Status          string    `json:"status"`
+	Amount          int       `json:"amount"`
+	Currency        string    `json:"currency"`
+	Items           int       `json:"items"`
+	StoredAt        time.Time `json:"stored_at"`
+}
+
+const (
+	EventOrderCreated       = "order.created"
+	EventOrderStatusChanged = "order.status_changed"
+)
+
+// filterFields are the order fields webhook subscriptions and live feed clients can filter on.
+var filterFields = map[string]func(Order) string{
+	"delivery_service": func(o Order) string { return o.DeliveryService },
+	"customer_id":      func(o Order) string { return o.CustomerID },
+	"locale":           func(o Order) string { return o.Locale },
+	"entry":            func(o Order) string { return o.Entry },
+	"status":           func(o Order) string { return o.Status },
+}
+
+func IsFilterField(name string) bool {
+	_, ok := filterFields[name]
+	return ok
+}
+
+// FilterValues returns the order's value of every filter field.
+func (o Order) FilterValues() map[string]string {
+	values := make(map[string]string, len(filterFields))
+	for field, get := range filterFields {
+		values[field] = get(o)
+	}
+	return values
+}
+
+// MatchesFilters tells whether every filter equals the order's field. Unknown fields never match.
+func (o Order) MatchesFilters(filters map[string]string) bool {
+	for field, want := range filters {
+		get, ok := filterFields[field]
+		if !ok || get(o) != want {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,139 @@
+package orderpb
+
+//go:generate protoc -I ../../api/proto --go_out=../.. --go_opt=module=test-task --go-grpc_out=../.. --go-grpc_opt=module=test-task order/v1/order.proto order/v1/order_service.proto
+
+import (
+	"test-task/internal/model"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func FromModel(o model.Order) *Order {
+	items := make([]*Item, 0, len(o.Items))
+	for _, it := range o.Items {
+		items = append(items, &Item{
+			ChrtId:      it.ChrtID,
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.RID,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmId:        it.NmID,
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+
+	return &Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDt:    o.Payment.PaymentDT,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+		Items:             items,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSign,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SmID),
+		DateCreated:       timestamppb.New(o.DateCreated),
+		OofShard:          o.OofShard,
+		Status:            o.Status,
+		Tags:              o.Tags,
+	}
+}
+
+func ToModel(o *Order) model.Order {
+	items := make([]model.Item, 0, len(o.GetItems()))
+	for _, it := range o.GetItems() {
+		items = append(items, model.Item{
+			ChrtID:      it.GetChrtId(),
+			TrackNumber: it.GetTrackNumber(),
+			Price:       int(it.GetPrice()),
+			RID:         it.GetRid(),
+			Name:        it.GetName(),
+			Sale:        int(it.GetSale()),
+			Size:        it.GetSize(),
+			TotalPrice:  int(it.GetTotalPrice()),
+			NmID:        it.GetNmId(),
+			Brand:       it.GetBrand(),
+			Status:      int(it.GetStatus()),
+		})
+	}
+
+	order := model.Order{
+		OrderUID:    o.GetOrderUid(),
+		TrackNumber: o.GetTrackNumber(),
+		Entry:       o.GetEntry(),
+		Delivery: model.Delivery{
+			Name:    o.GetDelivery().GetName(),
+			Phone:   o.GetDelivery().GetPhone(),
+			Zip:     o.GetDelivery().GetZip(),
+			City:    o.GetDelivery().GetCity(),
+			Address: o.GetDelivery().GetAddress(),
+			Region:  o.GetDelivery().GetRegion(),
+			Email:   o.GetDelivery().GetEmail(),
+		},
+		Payment: model.Payment{
+			Transaction:  o.GetPayment().GetTransaction(),
+			RequestID:    o.GetPayment().GetRequestId(),
+			Currency:     o.GetPayment().GetCurrency(),
+			Provider:     o.GetPayment().GetProvider(),
+			Amount:       int(o.GetPayment().GetAmount()),
+			PaymentDT:    o.GetPayment().GetPaymentDt(),
+			Bank:         o.GetPayment().GetBank(),
+			DeliveryCost: int(o.GetPayment().GetDeliveryCost()),
+			GoodsTotal:   int(o.GetPayment().GetGoodsTotal()),
+			CustomFee:    int(o.GetPayment().GetCustomFee()),
+		},
+		Items:           items,
+		Locale:          o.GetLocale(),
+		InternalSign:    o.GetInternalSignature(),
+		CustomerID:      o.GetCustomerId(),
+		DeliveryService: o.GetDeliveryService(),
+		ShardKey:        o.GetShardkey(),
+		SmID:            int(o.GetSmId()),
+		OofShard:        o.GetOofShard(),
+		Status:          o.GetStatus(),
+		Tags:            o.GetTags(),
+	}
+	if o.GetDateCreated() != nil {
+		order.DateCreated = o.GetDateCreated().AsTime()
+	}
+	return order
+}
+
+func Marshal(o model.Order) ([]byte, error) {
+	return proto.Marshal(FromModel(o))
+}
+
+func Unmarshal(data []byte) (model.Order, error) {
+	var pb Order
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return model.Order{}, err
+	}
+	return ToModel(&pb), nil
+}
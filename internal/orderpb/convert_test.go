@@ -0,0 +1,107 @@
+package orderpb
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"test-task/internal/model"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// roundTrip sends o through the protobuf wire format and back.
+func roundTrip(t *testing.T, o model.Order) model.Order {
+	t.Helper()
+	data, err := Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// assertSameJSON compares the orders by their JSON encoding, which is what API clients see.
+func assertSameJSON(t *testing.T, want, got model.Order) {
+	t.Helper()
+	w, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(w) != string(g) {
+		t.Fatalf("round trip changed the order:\nwant %s\n got %s", w, g)
+	}
+}
+
+func sampleOrder(t *testing.T) model.Order {
+	t.Helper()
+	data, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o model.Order
+	if err := json.Unmarshal(data, &o); err != nil {
+		t.Fatal(err)
+	}
+	o.DateCreated = o.DateCreated.UTC()
+	return o
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*model.Order)
+	}{
+		{"sample order", func(*model.Order) {}},
+		{"zero date", func(o *model.Order) { o.DateCreated = time.Time{} }},
+		{"nanoseconds", func(o *model.Order) { o.DateCreated = time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC) }},
+		{"empty items", func(o *model.Order) { o.Items = []model.Item{} }},
+		{"request id", func(o *model.Order) { o.Payment.RequestID = "req-42" }},
+		{"no request id", func(o *model.Order) { o.Payment.RequestID = "" }},
+		{"status", func(o *model.Order) { o.Status = model.StatusShipped }},
+		{"no status", func(o *model.Order) { o.Status = "" }},
+		{"tags", func(o *model.Order) { o.Tags = []string{"amount-mismatch", "high-delivery-cost"} }},
+		{"no tags", func(o *model.Order) { o.Tags = nil }},
+		{"zero values", func(o *model.Order) { *o = model.Order{Items: []model.Item{{}}} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := sampleOrder(t)
+			tt.change(&o)
+			assertSameJSON(t, o, roundTrip(t, o))
+		})
+	}
+}
+
+// A protobuf Timestamp holds an instant without a zone, so the order comes back in UTC.
+func TestRoundTripNormalizesDateToUTC(t *testing.T) {
+	o := sampleOrder(t)
+	o.DateCreated = time.Date(2024, 5, 1, 13, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	got := roundTrip(t, o)
+	if !got.DateCreated.Equal(o.DateCreated) || got.DateCreated.Location() != time.UTC {
+		t.Fatalf("date_created = %s, want %s in UTC", got.DateCreated, o.DateCreated)
+	}
+	o.DateCreated = o.DateCreated.UTC()
+	assertSameJSON(t, o, got)
+}
+
+func TestRoundTripFakeOrders(t *testing.T) {
+	faker := gofakeit.New(1)
+	for range 50 {
+		var o model.Order
+		if err := faker.Struct(&o); err != nil {
+			t.Fatal(err)
+		}
+		o.DateCreated = o.DateCreated.UTC()
+		assertSameJSON(t, o, roundTrip(t, o))
+	}
+}
@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"test-task/internal/config"
+	"test-task/internal/kafkaconn"
+	"test-task/internal/metrics"
+	"test-task/internal/repository"
+	"test-task/internal/tracing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EventTypeHeader = "event-type"
+	EventIDHeader   = "event-id"
+)
+
+var tracer = otel.Tracer("test-task/internal/outbox")
+
+// Relay publishes outbox rows to Kafka in the order they were written. Messages are keyed
+// by order UID, so events of one order always land in the same partition. A failed batch
+// stays at the head of the outbox and is retried with exponential backoff.
+type Relay struct {
+	repo         *repository.OutboxRepository
+	writer       *kafka.Writer
+	batchSize    int
+	pollInterval time.Duration
+	maxBackoff   time.Duration
+}
+
+func NewRelay(repo *repository.OutboxRepository, conn *kafkaconn.Connection) *Relay {
+	w := conn.Writer(config.OutboxTopic())
+	w.Balancer = &kafka.Hash{}
+	w.RequiredAcks = kafka.RequireAll
+	w.BatchSize = config.OutboxBatchSize()
+	w.BatchTimeout = 10 * time.Millisecond
+
+	return &Relay{
+		repo:         repo,
+		writer:       w,
+		batchSize:    config.OutboxBatchSize(),
+		pollInterval: config.OutboxPollInterval(),
+		maxBackoff:   config.OutboxMaxBackoff(),
+	}
+}
+
+// Run relays the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	log.Println("Outbox relay publishing to", r.writer.Topic)
+	backoff := r.pollInterval
+	for {
+		n, err := r.repo.ProcessBatch(ctx, r.batchSize, r.publish)
+
+		wait := r.pollInterval
+		switch {
+		case err != nil && ctx.Err() == nil:
+			metrics.OutboxFailures.Inc()
+			log.Printf("Outbox relay failed, retrying in %s: %v", backoff, err)
+			wait = backoff
+			backoff = min(backoff*2, r.maxBackoff)
+		case n == r.batchSize:
+			backoff = r.pollInterval
+			wait = 0
+		default:
+			backoff = r.pollInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping outbox relay...")
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (r *Relay) Close() {
+	if err := r.writer.Close(); err != nil {
+		log.Printf("failed to close outbox writer: %v", err)
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, msgs []repository.OutboxMessage) error {
+	spans := make([]trace.Span, 0, len(msgs))
+	messages := make([]kafka.Message, 0, len(msgs))
+	for _, m := range msgs {
+		msgCtx := otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(m.Headers))
+		msgCtx, span := tracer.Start(msgCtx, r.writer.Topic+" publish",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				attribute.String("messaging.system", "kafka"),
+				attribute.String("messaging.destination.name", r.writer.Topic),
+				attribute.Int64("outbox.id", m.ID),
+				attribute.String("order.uid", m.AggregateID),
+			),
+		)
+		spans = append(spans, span)
+
+		headers := []kafka.Header{
+			{Key: EventTypeHeader, Value: []byte(m.EventType)},
+			{Key: EventIDHeader, Value: []byte(strconv.FormatInt(m.ID, 10))},
+		}
+		otel.GetTextMapPropagator().Inject(msgCtx, tracing.NewHeaderCarrier(&headers))
+
+		messages = append(messages, kafka.Message{
+			Key:     []byte(m.AggregateID),
+			Value:   m.Payload,
+			Headers: headers,
+		})
+	}
+
+	err := r.writer.WriteMessages(ctx, messages...)
+	for _, span := range spans {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+	if err == nil {
+		metrics.OutboxPublished.Add(float64(len(msgs)))
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"test-task/internal/auth"
+	"test-task/internal/config"
+	"test-task/internal/metrics"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultClientTTL is used when no positive client TTL is configured.
+const defaultClientTTL = 10 * time.Minute
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter keeps one token bucket per caller for a route: the API key or JWT subject of an
+// authenticated caller, otherwise the client IP. It goes between auth.Identify and
+// auth.Middleware, so that requests failing authentication are limited per IP as well.
+type Limiter struct {
+	mtx     sync.Mutex
+	route   string
+	rps     rate.Limit
+	burst   int
+	ttl     time.Duration
+	clients map[string]*client
+}
+
+func NewLimiter(route string, limit config.RouteLimit, ttl time.Duration) *Limiter {
+	if ttl <= 0 {
+		ttl = defaultClientTTL
+	}
+	return &Limiter{
+		route:   route,
+		rps:     rate.Limit(limit.RPS),
+		burst:   limit.Burst,
+		ttl:     ttl,
+		clients: make(map[string]*client),
+	}
+}
+
+// ForRoute returns middleware limiting route with its configured limit, or a no-op when rate limiting is disabled.
+func ForRoute(route string) func(http.Handler) http.Handler {
+	if !config.RateLimitEnabled() {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	l := NewLimiter(route, config.RateLimitFor(route), config.RateLimitClientTTL())
+	go l.cleanup()
+	return l.Middleware
+}
+
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lim := l.get(clientKey(r))
+
+		now := time.Now()
+		res := lim.ReserveN(now, 1)
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.burst))
+
+		if delay := res.DelayFrom(now); !res.OK() || delay > 0 {
+			res.CancelAt(now)
+			retryAfter := int(math.Ceil(delay.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			metrics.RateLimitRejected.WithLabelValues(l.route).Inc()
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(lim.TokensAt(now))))
+		metrics.RateLimitAllowed.WithLabelValues(l.route).Inc()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) get(key string) *rate.Limiter {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.clients[key] = c
+		metrics.RateLimitClients.WithLabelValues(l.route).Set(float64(len(l.clients)))
+	}
+	c.lastSeen = time.Now()
+	return c.limiter
+}
+
+func (l *Limiter) cleanup() {
+	if l.ttl <= 0 {
+		return
+	}
+	ticker := time.NewTicker(l.ttl)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.mtx.Lock()
+		for key, c := range l.clients {
+			if time.Since(c.lastSeen) > l.ttl {
+				delete(l.clients, key)
+			}
+		}
+		metrics.RateLimitClients.WithLabelValues(l.route).Set(float64(len(l.clients)))
+		l.mtx.Unlock()
+	}
+}
+
+func clientKey(r *http.Request) string {
+	if p, ok := auth.PrincipalFromContext(r.Context()); ok && p.ID != auth.AnonymousID {
+		return "key:" + p.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
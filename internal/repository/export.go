@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"test-task/internal/model"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ExportRange selects the orders created in [From, To), continuing after After when it is set.
+type ExportRange struct {
+	From  time.Time
+	To    time.Time
+	After *OrderCursor
+}
+
+// StreamOrders reads the orders of rng oldest first by (date_created, order_uid) through a
+// server-side cursor, batchSize orders per FETCH, and passes each batch with its items to fn.
+// The whole read runs in one read-only REPEATABLE READ transaction, so the export is a
+// consistent snapshot however long it takes. An error from fn stops the read and is returned;
+// it says nothing about the database (a client that went away mid-download, say), so only
+// errors of the queries themselves count towards the circuit breaker.
+func (r *OrderRepository) StreamOrders(ctx context.Context, rng ExportRange, batchSize int, fn func([]model.Order) error) (err error) {
+	ctx, span := tracer.Start(ctx, "OrderRepository.StreamOrders", trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.Int("batch.size", batchSize),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err = r.breaker.Allow(); err != nil {
+		return err
+	}
+	var stopped bool
+	defer func() { r.breaker.Record(!stopped && IsDBFailure(err)) }()
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("error starting export transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	declare := `
+		DECLARE export_orders NO SCROLL CURSOR FOR
+		SELECT` + orderColumns + `
+		WHERE o.date_created >= $1 AND o.date_created < $2`
+	args := []any{rng.From, rng.To}
+	if rng.After != nil {
+		declare += ` AND (o.date_created, o.order_uid) > ($3, $4)`
+		args = append(args, rng.After.DateCreated, rng.After.OrderUID)
+	}
+	declare += `
+		ORDER BY o.date_created, o.order_uid;`
+	if _, err = tx.ExecContext(ctx, declare, args...); err != nil {
+		return fmt.Errorf("error declaring export cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_orders;", batchSize)
+	for {
+		var batch []model.Order
+		if batch, err = fetchOrders(ctx, tx, fetch); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		uids := make([]string, len(batch))
+		for i, o := range batch {
+			uids[i] = o.OrderUID
+		}
+		var items map[string][]model.Item
+		if items, err = queryItems(ctx, tx, uids); err != nil {
+			return err
+		}
+		for i := range batch {
+			batch[i].Items = items[batch[i].OrderUID]
+		}
+
+		if err = fn(batch); err != nil {
+			stopped = true
+			return err
+		}
+	}
+}
+
+func fetchOrders(ctx context.Context, tx *sql.Tx, fetch string) ([]model.Order, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching export cursor: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []model.Order
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating export cursor: %w", err)
+	}
+	return orders, nil
+}
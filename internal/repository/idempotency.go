@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrIdempotencyKeyNotFound = errors.New("error idempotency key not found in DB")
+
+type IdempotentResponse struct {
+	RequestHash string
+	StatusCode  int
+	Body        []byte
+}
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+func (r *IdempotencyRepository) Get(ctx context.Context, key string) (IdempotentResponse, error) {
+	var resp IdempotentResponse
+	err := r.db.QueryRowContext(ctx,
+		`SELECT request_hash, status_code, response FROM idempotency_keys WHERE key = $1`, key,
+	).Scan(&resp.RequestHash, &resp.StatusCode, &resp.Body)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return IdempotentResponse{}, ErrIdempotencyKeyNotFound
+		}
+		return IdempotentResponse{}, fmt.Errorf("error reading idempotency key: %w", err)
+	}
+	return resp, nil
+}
+
+// reservationTimeout is how long a reserved key may stay unfinished before another request
+// can take it over, in case the request holding it died without releasing it.
+const reservationTimeout = 5 * time.Minute
+
+// Reserve claims key for a request that is about to be processed. It returns false if the
+// key is already taken: finished, or still being processed by another request (StatusCode 0).
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key string) (bool, error) {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE key = $1 AND status_code = 0 AND created_at < $2`,
+		key, time.Now().Add(-reservationTimeout),
+	)
+	if err != nil {
+		return false, fmt.Errorf("error expiring idempotency key: %w", err)
+	}
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (key, request_hash, status_code, response) VALUES ($1, '', 0, '') ON CONFLICT (key) DO NOTHING`,
+		key,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error reserving idempotency key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error reserving idempotency key: %w", err)
+	}
+	return n == 1, nil
+}
+
+// Save stores the response for a key reserved with Reserve.
+func (r *IdempotencyRepository) Save(ctx context.Context, key string, resp IdempotentResponse) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET request_hash = $2, status_code = $3, response = $4 WHERE key = $1`,
+		key, resp.RequestHash, resp.StatusCode, resp.Body,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release drops a reservation whose request failed, so that the client can retry it.
+func (r *IdempotencyRepository) Release(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND status_code = 0`, key)
+	if err != nil {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
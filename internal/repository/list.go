@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"test-task/internal/model"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OrderFilter narrows ListOrders; empty fields are not filtered on.
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Status          string
+}
+
+// OrderCursor is the position of the last order of a page: orders are listed newest first
+// by (date_created, order_uid).
+type OrderCursor struct {
+	DateCreated time.Time
+	OrderUID    string
+}
+
+var ErrInvalidCursor = errors.New("repository: invalid order cursor")
+
+// CursorAt is the cursor positioned at order.
+func CursorAt(order model.Order) OrderCursor {
+	return OrderCursor{DateCreated: order.DateCreated, OrderUID: order.OrderUID}
+}
+
+// Token encodes the cursor as an opaque URL-safe string, used for page tokens and export
+// checkpoints.
+func (c OrderCursor) Token() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.DateCreated.UTC().Format(time.RFC3339Nano) + "|" + c.OrderUID))
+}
+
+// ParseOrderCursor decodes a Token.
+func ParseOrderCursor(token string) (OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return OrderCursor{}, ErrInvalidCursor
+	}
+	ts, uid, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return OrderCursor{}, ErrInvalidCursor
+	}
+	created, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return OrderCursor{}, ErrInvalidCursor
+	}
+	return OrderCursor{DateCreated: created, OrderUID: uid}, nil
+}
+
+// orderColumns selects an order with its delivery and payment, in the order scanOrder reads them.
+const orderColumns = `
+			o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status, o.tags, o.updated_at,
+			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+			p.transaction_id, p.request_id, p.currency, p.provider, p.amount,
+			p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+		FROM orders AS o
+		JOIN deliveries AS d ON o.delivery_id = d.id
+		JOIN payments AS p ON o.payment_transaction_id = p.transaction_id`
+
+func scanOrder(rows *sql.Rows) (o model.Order, err error) {
+	err = rows.Scan(
+		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSign,
+		&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard, &o.Status, (*tagsColumn)(&o.Tags), &o.UpdatedAt,
+		&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City, &o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
+		&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency, &o.Payment.Provider, &o.Payment.Amount,
+		&o.Payment.PaymentDT, &o.Payment.Bank, &o.Payment.DeliveryCost, &o.Payment.GoodsTotal, &o.Payment.CustomFee,
+	)
+	return o, err
+}
+
+// ListOrders returns up to limit orders matching filter that come after the cursor, newest
+// first. Items are not loaded; use ItemsByOrderUIDs to fetch them for a whole page at once.
+func (r *OrderRepository) ListOrders(ctx context.Context, filter OrderFilter, after *OrderCursor, limit int) (orders []model.Order, err error) {
+	ctx, span := tracer.Start(ctx, "OrderRepository.ListOrders", trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.Int("limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err = r.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	defer func() { r.breaker.Record(IsDBFailure(err)) }()
+
+	var where []string
+	var args []any
+	cond := func(expr string, values ...any) {
+		for i := range values {
+			expr = strings.Replace(expr, "?", fmt.Sprintf("$%d", len(args)+i+1), 1)
+		}
+		where = append(where, expr)
+		args = append(args, values...)
+	}
+	if filter.CustomerID != "" {
+		cond("o.customer_id = ?", filter.CustomerID)
+	}
+	if filter.TrackNumber != "" {
+		cond("o.track_number = ?", filter.TrackNumber)
+	}
+	if filter.DeliveryService != "" {
+		cond("o.delivery_service = ?", filter.DeliveryService)
+	}
+	if filter.Status != "" {
+		cond("o.status = ?", filter.Status)
+	}
+	if after != nil {
+		cond("(o.date_created, o.order_uid) < (?, ?)", after.DateCreated, after.OrderUID)
+	}
+
+	query := `
+		SELECT` + orderColumns
+	if len(where) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf("\n\t\tORDER BY o.date_created DESC, o.order_uid DESC\n\t\tLIMIT %d;", limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing orders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o model.Order
+		o, err = scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orders: %w", err)
+	}
+	return orders, nil
+}
+
+// ItemsByOrderUIDs loads the items of all given orders with a single query, keyed by order UID.
+func (r *OrderRepository) ItemsByOrderUIDs(ctx context.Context, uids []string) (items map[string][]model.Item, err error) {
+	ctx, span := tracer.Start(ctx, "OrderRepository.ItemsByOrderUIDs", trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.Int("order.count", len(uids)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	items = make(map[string][]model.Item, len(uids))
+	if len(uids) == 0 {
+		return items, nil
+	}
+
+	if err = r.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	defer func() { r.breaker.Record(IsDBFailure(err)) }()
+
+	return queryItems(ctx, r.db, uids)
+}
+
+// queryer is what item loading needs from *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryItems(ctx context.Context, q queryer, uids []string) (map[string][]model.Item, error) {
+	itemsQuery := `
+		SELECT
+			oi.order_uid, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale,
+			i.size, i.total_price, i.nm_id, i.brand, i.status
+		FROM items AS i
+		JOIN order_items AS oi ON i.chrt_id = oi.chrt_id
+		WHERE oi.order_uid = ANY($1)
+		ORDER BY oi.order_uid, i.chrt_id;`
+
+	rows, err := q.QueryContext(ctx, itemsQuery, uids)
+	if err != nil {
+		return nil, fmt.Errorf("error querying items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make(map[string][]model.Item, len(uids))
+	for rows.Next() {
+		var uid string
+		var item model.Item
+		err = rows.Scan(
+			&uid, &item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name, &item.Sale,
+			&item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning item: %w", err)
+		}
+		items[uid] = append(items[uid], item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating items: %w", err)
+	}
+	return items, nil
+}
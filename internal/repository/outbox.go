@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+type OutboxMessage struct {
+	ID          int64
+	EventType   string
+	AggregateID string
+	Payload     []byte
+	Headers     map[string]string
+	Attempts    int
+}
+
+// insertOutbox adds an event to the outbox inside tx, together with the current trace
+// context so the relay can continue the trace when it publishes the event.
+func insertOutbox(ctx context.Context, tx *sql.Tx, eventType, aggregateID string, event any) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	headers, err := json.Marshal(carrier)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event headers: %w", eventType, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO outbox (event_type, aggregate_id, payload, headers) VALUES ($1, $2, $3, $4)`,
+		eventType, aggregateID, payload, headers)
+	if err != nil {
+		return fmt.Errorf("failed to insert %s event for %s into outbox: %w", eventType, aggregateID, err)
+	}
+	return nil
+}
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// ProcessBatch locks up to limit of the oldest unsent messages in id order and hands them
+// to publish. On success they are marked sent; on failure their attempt counter and last
+// error are updated and they stay at the head of the outbox. The row locks keep a second
+// relay from publishing the same messages or overtaking them.
+func (r *OutboxRepository) ProcessBatch(ctx context.Context, limit int, publish func(ctx context.Context, msgs []OutboxMessage) error) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, event_type, aggregate_id, payload, headers, attempts
+		FROM outbox
+		WHERE sent_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE;`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error querying outbox: %w", err)
+	}
+
+	var msgs []OutboxMessage
+	var ids []int64
+	for rows.Next() {
+		var m OutboxMessage
+		var headers []byte
+		if err := rows.Scan(&m.ID, &m.EventType, &m.AggregateID, &m.Payload, &headers, &m.Attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning outbox message: %w", err)
+		}
+		if err := json.Unmarshal(headers, &m.Headers); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error decoding headers of outbox message %d: %w", m.ID, err)
+		}
+		msgs = append(msgs, m)
+		ids = append(ids, m.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating outbox: %w", err)
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	if pubErr := publish(ctx, msgs); pubErr != nil {
+		_, err = tx.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE id = ANY($2)`, pubErr.Error(), ids)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record outbox failure: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("failed to record outbox failure: %w", err)
+		}
+		return 0, pubErr
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE outbox SET sent_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark outbox messages sent: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to mark outbox messages sent: %w", err)
+	}
+	return len(msgs), nil
+}
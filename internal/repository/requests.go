@@ -1,30 +1,62 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"time"
 
+	"test-task/internal/breaker"
 	"test-task/internal/model"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("test-task/internal/repository")
+
 var ErrOrderIter = errors.New("error in orders iterations")
 var ErrOrderNotFound = errors.New("error not found in DB")
+var ErrOrderExists = errors.New("error order already exists in DB")
 
+// OrderRepository guards every context-aware query with the database circuit breaker:
+// while it is open the methods fail fast with breaker.ErrOpen.
 type OrderRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	breaker *breaker.Breaker
+	hooks   []TxHook
+}
+
+// TxHook is called inside the transaction that stores an order change, so whatever it
+// writes is committed or rolled back together with the change. A returned error fails it.
+type TxHook interface {
+	OrderCreatedTx(ctx context.Context, tx *sql.Tx, order model.Order) error
+	OrderStatusChangedTx(ctx context.Context, tx *sql.Tx, order model.Order, change model.StatusChange) error
+}
+
+func NewOrderRepository(db *sql.DB, breaker *breaker.Breaker) *OrderRepository {
+	return &OrderRepository{db: db, breaker: breaker}
 }
 
-func NewOrderRepository(db *sql.DB) *OrderRepository {
-	return &OrderRepository{db: db}
+// AddTxHook registers h. Hooks must be added before the repository stores any order.
+func (r *OrderRepository) AddTxHook(h TxHook) {
+	r.hooks = append(r.hooks, h)
 }
 
 func (r *OrderRepository) GetLastNOrders(limit int) ([]model.Order, error) {
 	mainQuery := fmt.Sprintf(`
 		SELECT
 			o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
-			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status, o.tags, o.updated_at,
 			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
 			p.transaction_id, p.request_id, p.currency, p.provider, p.amount,
 			p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
@@ -46,7 +78,7 @@ func (r *OrderRepository) GetLastNOrders(limit int) ([]model.Order, error) {
 		var o model.Order
 		err := rows.Scan(
 			&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSign,
-			&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard,
+			&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard, &o.Status, (*tagsColumn)(&o.Tags), &o.UpdatedAt,
 			&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City, &o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
 			&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency, &o.Payment.Provider, &o.Payment.Amount,
 			&o.Payment.PaymentDT, &o.Payment.Bank, &o.Payment.DeliveryCost, &o.Payment.GoodsTotal, &o.Payment.CustomFee,
@@ -107,13 +139,32 @@ func (r *OrderRepository) GetLastNOrders(limit int) ([]model.Order, error) {
 	return orders, nil
 }
 
-func (r *OrderRepository) GetByUID(uid string) (model.Order, error) {
-	var o model.Order
+func (r *OrderRepository) GetByUID(ctx context.Context, uid string) (o model.Order, err error) {
+	ctx, span := tracer.Start(ctx, "OrderRepository.GetByUID", trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("order.uid", uid),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err = r.breaker.Allow(); err != nil {
+		return model.Order{}, err
+	}
+	defer func() { r.breaker.Record(IsDBFailure(err)) }()
+
+	return getOrder(ctx, r.db, uid)
+}
 
+// rowQueryer is what loading a single order needs from *sql.DB and *sql.Tx.
+type rowQueryer interface {
+	queryer
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getOrder(ctx context.Context, q rowQueryer, uid string) (o model.Order, err error) {
 	mainQuery := `
 		SELECT
 			o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
-			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status, o.tags, o.updated_at,
 			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
 			p.transaction_id, p.request_id, p.currency, p.provider, p.amount,
 			p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
@@ -122,9 +173,9 @@ func (r *OrderRepository) GetByUID(uid string) (model.Order, error) {
 		JOIN payments AS p ON o.payment_transaction_id = p.transaction_id
 		WHERE o.order_uid = $1;`
 
-	err := r.db.QueryRow(mainQuery, uid).Scan(
+	err = q.QueryRowContext(ctx, mainQuery, uid).Scan(
 		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSign,
-		&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard,
+		&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard, &o.Status, (*tagsColumn)(&o.Tags), &o.UpdatedAt,
 		&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City, &o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
 		&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency, &o.Payment.Provider, &o.Payment.Amount,
 		&o.Payment.PaymentDT, &o.Payment.Bank, &o.Payment.DeliveryCost, &o.Payment.GoodsTotal, &o.Payment.CustomFee,
@@ -144,7 +195,7 @@ func (r *OrderRepository) GetByUID(uid string) (model.Order, error) {
 		JOIN order_items AS oi ON i.chrt_id = oi.chrt_id
 		WHERE oi.order_uid = $1;`
 
-	rows, err := r.db.Query(itemsQuery, uid)
+	rows, err := q.QueryContext(ctx, itemsQuery, uid)
 	if err != nil {
 		return model.Order{}, fmt.Errorf("error querying items for order %s: %w", uid, err)
 	}
@@ -169,29 +220,114 @@ func (r *OrderRepository) GetByUID(uid string) (model.Order, error) {
 	return o, nil
 }
 
-func (r *OrderRepository) SaveOrder(order *model.Order) error {
-	tx, err := r.db.Begin()
+func (r *OrderRepository) SaveOrder(ctx context.Context, order *model.Order) (err error) {
+	ctx, span := tracer.Start(ctx, "OrderRepository.SaveOrder", trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("order.uid", order.OrderUID),
+		attribute.Int("order.items", len(order.Items)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err = r.breaker.Allow(); err != nil {
+		return err
+	}
+	defer func() { r.breaker.Record(IsDBFailure(err)) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	if err = r.saveOrderTx(ctx, tx, order); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveOrders stores a batch of orders in one transaction. Each order is saved behind a
+// savepoint, so a duplicate or an order the database rejects does not undo the others:
+// errs[i] is the outcome for orders[i] (nil, ErrOrderExists or the failed statement's
+// error). err is set when the batch as a whole failed, in which case nothing was stored.
+func (r *OrderRepository) SaveOrders(ctx context.Context, orders []model.Order) (errs []error, err error) {
+	ctx, span := tracer.Start(ctx, "OrderRepository.SaveOrders", trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.Int("order.count", len(orders)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err = r.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	defer func() { r.breaker.Record(IsDBFailure(err)) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	errs = make([]error, len(orders))
+	for i := range orders {
+		if _, err = tx.ExecContext(ctx, `SAVEPOINT save_order`); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		if errs[i] = r.saveOrderTx(ctx, tx, &orders[i]); errs[i] != nil {
+			if _, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT save_order`); err != nil {
+				return nil, fmt.Errorf("failed to roll back order %s: %w", orders[i].OrderUID, err)
+			}
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT save_order`); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return errs, nil
+}
+
+// saveOrderTx inserts order with its delivery, payment, items, first status history entry
+// and outbox event within tx and runs the hooks. It returns ErrOrderExists if the order is
+// already stored.
+func (r *OrderRepository) saveOrderTx(ctx context.Context, tx *sql.Tx, order *model.Order) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`, order.OrderUID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check order existence: %w", err)
+	}
+	if exists {
+		return ErrOrderExists
+	}
+
 	deliveryQuery := `INSERT INTO deliveries (name, phone, zip, city, address, region, email) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
 	var deliveryID int
-	err = tx.QueryRow(deliveryQuery, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email).Scan(&deliveryID)
+	err = tx.QueryRowContext(ctx, deliveryQuery, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email).Scan(&deliveryID)
 	if err != nil {
 		return fmt.Errorf("failed to insert delivery: %w", err)
 	}
 
 	paymentQuery := `INSERT INTO payments (transaction_id, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
-	_, err = tx.Exec(paymentQuery, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
+	_, err = tx.ExecContext(ctx, paymentQuery, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: payment %s already stored", ErrOrderExists, order.Payment.Transaction)
+		}
 		return fmt.Errorf("failed to insert payment: %w", err)
 	}
 
-	orderQuery := `INSERT INTO orders (order_uid, track_number, entry, delivery_id, payment_transaction_id, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
-	_, err = tx.Exec(orderQuery, order.OrderUID, order.TrackNumber, order.Entry, deliveryID, order.Payment.Transaction, order.Locale, order.InternalSign, order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard)
+	if order.Status == "" {
+		order.Status = model.StatusCreated
+	}
+
+	orderQuery := `INSERT INTO orders (order_uid, track_number, entry, delivery_id, payment_transaction_id, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status, tags) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING updated_at`
+	err = tx.QueryRowContext(ctx, orderQuery, order.OrderUID, order.TrackNumber, order.Entry, deliveryID, order.Payment.Transaction, order.Locale, order.InternalSign, order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard, order.Status, tagsColumn(order.Tags)).Scan(&order.UpdatedAt)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrOrderExists
+		}
 		return fmt.Errorf("failed to insert order: %w", err)
 	}
 
@@ -199,15 +335,116 @@ func (r *OrderRepository) SaveOrder(order *model.Order) error {
 	orderItemQuery := `INSERT INTO order_items (order_uid, chrt_id) VALUES ($1, $2)`
 
 	for _, item := range order.Items {
-		_, err = tx.Exec(itemQuery, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
+		_, err = tx.ExecContext(ctx, itemQuery, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
 		if err != nil {
 			return fmt.Errorf("failed to insert item with chrt_id %d: %w", item.ChrtID, err)
 		}
-		_, err = tx.Exec(orderItemQuery, order.OrderUID, item.ChrtID)
+		_, err = tx.ExecContext(ctx, orderItemQuery, order.OrderUID, item.ChrtID)
 		if err != nil {
 			return fmt.Errorf("failed to insert into order_items for order %s and chrt_id %d: %w", order.OrderUID, item.ChrtID, err)
 		}
 	}
 
-	return tx.Commit()
+	_, err = tx.ExecContext(ctx, insertHistoryQuery, order.OrderUID, nil, order.Status, nil, "ingest")
+	if err != nil {
+		return fmt.Errorf("failed to insert status history for order %s: %w", order.OrderUID, err)
+	}
+
+	err = insertOutbox(ctx, tx, model.EventOrderStored, order.OrderUID, model.OrderStoredEvent{
+		Type:            model.EventOrderStored,
+		OrderUID:        order.OrderUID,
+		TrackNumber:     order.TrackNumber,
+		CustomerID:      order.CustomerID,
+		DeliveryService: order.DeliveryService,
+		Status:          order.Status,
+		Amount:          order.Payment.Amount,
+		Currency:        order.Payment.Currency,
+		Items:           len(order.Items),
+		StoredAt:        time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, h := range r.hooks {
+		if err := h.OrderCreatedTx(ctx, tx, *order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagsColumn stores order tags in the JSONB tags column.
+type tagsColumn []string
+
+func (t tagsColumn) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(t))
+	return string(b), err
+}
+
+func (t *tagsColumn) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unsupported tags column type %T", src)
+	}
+	var tags []string
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	*t = tags
+	return nil
+}
+
+// IsDBFailure tells whether err says something about the database's health rather than
+// about the data: SQLSTATE classes 08 (connection exception), 53 (insufficient resources)
+// and 57 (operator intervention, e.g. a shutdown or statement timeout), network errors and
+// timeouts. Data errors such as constraint violations (class 23) or invalid values (22) do
+// not count, and neither does a caller giving up (context.Canceled).
+func IsDBFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code[:min(2, len(pgErr.Code))] {
+		case "08", "53", "57":
+			return true
+		}
+		return false
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
 }
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"test-task/internal/model"
+)
+
+var ErrStatusConflict = errors.New("error order status changed concurrently")
+
+const insertHistoryQuery = `INSERT INTO order_status_history (order_uid, from_status, to_status, reason, source) VALUES ($1, $2, $3, $4, $5) RETURNING changed_at`
+
+func (r *OrderRepository) GetStatus(ctx context.Context, uid string) (status string, err error) {
+	if err = r.breaker.Allow(); err != nil {
+		return "", err
+	}
+	defer func() { r.breaker.Record(IsDBFailure(err)) }()
+
+	err = r.db.QueryRowContext(ctx, `SELECT status FROM orders WHERE order_uid = $1`, uid).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrOrderNotFound
+		}
+		return "", fmt.Errorf("error reading status of order %s: %w", uid, err)
+	}
+	return status, nil
+}
+
+// UpdateStatus moves the order from change.FromStatus to change.ToStatus, records the
+// transition in the history table and runs the hooks with the updated order. It fails
+// with ErrStatusConflict if the stored status is no longer change.FromStatus.
+func (r *OrderRepository) UpdateStatus(ctx context.Context, change *model.StatusChange) (err error) {
+	ctx, span := tracer.Start(ctx, "OrderRepository.UpdateStatus")
+	defer func() { endSpan(span, err) }()
+
+	if err = r.breaker.Allow(); err != nil {
+		return err
+	}
+	defer func() { r.breaker.Record(IsDBFailure(err)) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE orders SET status = $1, updated_at = now() WHERE order_uid = $2 AND status = $3`,
+		change.ToStatus, change.OrderUID, change.FromStatus)
+	if err != nil {
+		return fmt.Errorf("failed to update status of order %s: %w", change.OrderUID, err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update status of order %s: %w", change.OrderUID, err)
+	} else if n == 0 {
+		return ErrStatusConflict
+	}
+
+	err = tx.QueryRowContext(ctx, insertHistoryQuery,
+		change.OrderUID, change.FromStatus, change.ToStatus, nullString(change.Reason), change.Source,
+	).Scan(&change.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert status history for order %s: %w", change.OrderUID, err)
+	}
+
+	if len(r.hooks) > 0 {
+		order, err := getOrder(ctx, tx, change.OrderUID)
+		if err != nil {
+			return err
+		}
+		for _, h := range r.hooks {
+			if err := h.OrderStatusChangedTx(ctx, tx, order, *change); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *OrderRepository) GetStatusHistory(ctx context.Context, uid string) (history []model.StatusChange, err error) {
+	if err = r.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	defer func() { r.breaker.Record(IsDBFailure(err)) }()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT order_uid, COALESCE(from_status, ''), to_status, COALESCE(reason, ''), source, changed_at
+		FROM order_status_history
+		WHERE order_uid = $1
+		ORDER BY changed_at, id;`, uid)
+	if err != nil {
+		return nil, fmt.Errorf("error querying status history for order %s: %w", uid, err)
+	}
+	defer rows.Close()
+
+	history = []model.StatusChange{}
+	for rows.Next() {
+		var c model.StatusChange
+		if err := rows.Scan(&c.OrderUID, &c.FromStatus, &c.ToStatus, &c.Reason, &c.Source, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("error scanning status history for order %s: %w", uid, err)
+		}
+		history = append(history, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating status history for order %s: %w", uid, err)
+	}
+	return history, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
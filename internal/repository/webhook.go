@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrWebhookNotFound = errors.New("error webhook subscription not found in DB")
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookSubscription struct {
+	ID         int64             `json:"id"`
+	URL        string            `json:"url"`
+	Secret     string            `json:"secret,omitempty"`
+	EventTypes []string          `json:"event_types"`
+	Filters    map[string]string `json:"filters,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscription_id"`
+	EventType      string          `json:"event_type"`
+	OrderUID       string          `json:"order_uid"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+
+	// URL and Secret of the subscription, filled in by ClaimDue.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, sub *WebhookSubscription) error {
+	eventTypes, err := json.Marshal(sub.EventTypes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event types: %w", err)
+	}
+	filters, err := json.Marshal(sub.Filters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal filters: %w", err)
+	}
+
+	err = r.db.QueryRowContext(ctx,
+		`INSERT INTO webhook_subscriptions (url, secret, event_types, filters) VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		sub.URL, sub.Secret, eventTypes, filters,
+	).Scan(&sub.ID, &sub.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, url, secret, event_types, filters, created_at FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+func (r *WebhookRepository) GetSubscription(ctx context.Context, id int64) (WebhookSubscription, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT id, url, secret, event_types, filters, created_at FROM webhook_subscriptions WHERE id = $1`, id)
+	sub, err := scanSubscription(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return WebhookSubscription{}, ErrWebhookNotFound
+	}
+	return sub, err
+}
+
+// DeleteSubscription removes the subscription together with its delivery log.
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook subscription %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("error deleting webhook subscription %d: %w", id, err)
+	} else if n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// EnqueueTx adds within tx one pending delivery, due immediately, for every subscription to
+// eventType whose filters all equal the order's values in fields. Matching is done by the
+// insert itself, so an order event costs one statement however many subscriptions exist.
+func (r *WebhookRepository) EnqueueTx(ctx context.Context, tx *sql.Tx, eventType, orderUID string, fields map[string]string, payload []byte) error {
+	values, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to marshal filter values: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_type, order_uid, payload)
+		SELECT id, $1, $2, $3
+		FROM webhook_subscriptions
+		WHERE event_types @> jsonb_build_array($1::TEXT) AND filters <@ $4::JSONB`,
+		eventType, orderUID, payload, values)
+	if err != nil {
+		return fmt.Errorf("error enqueueing %s webhooks for order %s: %w", eventType, orderUID, err)
+	}
+	return nil
+}
+
+// ClaimDue picks up to limit pending deliveries whose next attempt is due and pushes their
+// next attempt lease into the future, so that another worker does not pick them up while
+// they are being sent. A worker that dies mid-delivery leaves them to be retried after lease.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries AS d
+		SET next_attempt_at = now() + $2 * INTERVAL '1 millisecond'
+		FROM webhook_subscriptions AS s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.subscription_id, d.event_type, d.order_uid, d.payload, d.attempts, d.created_at, s.url, s.secret;`,
+		limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		d := WebhookDelivery{Status: DeliveryPending}
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventType, &d.OrderUID, &payload, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt stores the outcome of one delivery attempt. A zero next time marks the
+// delivery as finished: delivered if status is DeliveryDelivered, failed otherwise.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, id int64, status string, statusCode int, attemptErr string, next time.Time) error {
+	var nextAttempt sql.NullTime
+	if !next.IsZero() {
+		nextAttempt = sql.NullTime{Time: next, Valid: true}
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2,
+			attempts = attempts + 1,
+			last_status_code = $3,
+			last_error = $4,
+			next_attempt_at = $5,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN now() END
+		WHERE id = $1`,
+		id, status, sql.NullInt64{Int64: int64(statusCode), Valid: statusCode != 0}, nullString(attemptErr), nextAttempt)
+	if err != nil {
+		return fmt.Errorf("error recording attempt of webhook delivery %d: %w", id, err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the newest deliveries of a subscription, optionally only those
+// in the given status.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID int64, status string, limit int) ([]WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, subscription_id, event_type, order_uid, payload, status, attempts, next_attempt_at,
+			COALESCE(last_status_code, 0), COALESCE(last_error, ''), created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3;`, subscriptionID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+		var next, delivered sql.NullTime
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventType, &d.OrderUID, &payload, &d.Status, &d.Attempts, &next,
+			&d.LastStatusCode, &d.LastError, &d.CreatedAt, &delivered)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		d.Payload = payload
+		if next.Valid {
+			d.NextAttemptAt = &next.Time
+		}
+		if delivered.Valid {
+			d.DeliveredAt = &delivered.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(row rowScanner) (WebhookSubscription, error) {
+	var sub WebhookSubscription
+	var eventTypes, filters []byte
+	if err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &eventTypes, &filters, &sub.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return WebhookSubscription{}, err
+		}
+		return WebhookSubscription{}, fmt.Errorf("error scanning webhook subscription: %w", err)
+	}
+	if err := json.Unmarshal(eventTypes, &sub.EventTypes); err != nil {
+		return WebhookSubscription{}, fmt.Errorf("error decoding event types of webhook %d: %w", sub.ID, err)
+	}
+	if err := json.Unmarshal(filters, &sub.Filters); err != nil {
+		return WebhookSubscription{}, fmt.Errorf("error decoding filters of webhook %d: %w", sub.ID, err)
+	}
+	return sub, nil
+}
@@ -0,0 +1,107 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hamba/avro/v2"
+)
+
+var (
+	ErrSchemaNotFound = errors.New("schemaregistry: schema not found")
+	// ErrUnavailable wraps failures to reach the registry or get an answer from it, as
+	// opposed to a schema that does not exist or cannot be used. Retrying may help.
+	ErrUnavailable = errors.New("schemaregistry: registry unavailable")
+)
+
+// Client resolves Confluent schema IDs to parsed Avro schemas. Schemas are immutable
+// per ID in the registry, so every successful lookup is cached for the process lifetime.
+type Client struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+
+	mtx   sync.RWMutex
+	cache map[int]avro.Schema
+}
+
+func NewClient(baseURL, username, password string) *Client {
+	return &Client{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: 10 * time.Second},
+		cache:    make(map[int]avro.Schema),
+	}
+}
+
+func (c *Client) Schema(ctx context.Context, id int) (avro.Schema, error) {
+	c.mtx.RLock()
+	schema, ok := c.cache[id]
+	c.mtx.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	raw, err := c.fetch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Each schema gets its own parse cache: writer schemas of different IDs usually share
+	// a full name, and the global avro cache would hand back whichever was parsed first.
+	schema, err = avro.ParseWithCache(raw, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("schemaregistry: invalid avro schema %d: %w", id, err)
+	}
+
+	c.mtx.Lock()
+	c.cache[id] = schema
+	c.mtx.Unlock()
+	return schema, nil
+}
+
+func (c *Client) fetch(ctx context.Context, id int) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/schemas/ids/"+strconv.Itoa(id), nil)
+	if err != nil {
+		return "", fmt.Errorf("schemaregistry: invalid url: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to fetch schema %d: %w", ErrUnavailable, id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: id %d", ErrSchemaNotFound, id)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("%w: failed to fetch schema %d: status %d: %s", ErrUnavailable, id, resp.StatusCode, body)
+	}
+
+	var payload struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("%w: invalid response for schema %d: %w", ErrUnavailable, id, err)
+	}
+	if payload.SchemaType != "" && payload.SchemaType != "AVRO" {
+		return "", fmt.Errorf("schemaregistry: schema %d is %s, not AVRO", id, payload.SchemaType)
+	}
+	return payload.Schema, nil
+}
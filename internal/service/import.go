@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"test-task/internal/model"
+	"test-task/internal/repository"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// ImportOrders is the batch counterpart of ProcessNewOrder: it validates the orders, stores
+// the valid ones in a single transaction, caches them and notifies observers. errs[i] is
+// the outcome for orders[i]: nil, a *ValidationError, ErrDuplicateOrder or the database
+// error for that order. err is set when the batch could not be stored at all.
+func (targ *OrderService) ImportOrders(ctx context.Context, orders []model.Order) (errs []error, err error) {
+	ctx, span := tracer.Start(ctx, "OrderService.ImportOrders")
+	defer span.End()
+	span.SetAttributes(attribute.Int("order.count", len(orders)))
+
+	errs = make([]error, len(orders))
+	valid := make([]model.Order, 0, len(orders))
+	positions := make([]int, 0, len(orders))
+	for i, order := range orders {
+		if err := targ.prepareNewOrder(&order); err != nil {
+			errs[i] = err
+			continue
+		}
+		valid = append(valid, order)
+		positions = append(positions, i)
+	}
+	if len(valid) == 0 {
+		return errs, nil
+	}
+
+	saveErrs, err := targ.repo.SaveOrders(ctx, valid)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	for j, saveErr := range saveErrs {
+		i := positions[j]
+		switch {
+		case saveErr == nil:
+			targ.cache.Set(valid[j])
+			targ.notifyCreated(ctx, valid[j])
+		case errors.Is(saveErr, repository.ErrOrderExists):
+			errs[i] = ErrDuplicateOrder
+		default:
+			errs[i] = saveErr
+		}
+	}
+	return errs, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"test-task/internal/model"
+	"test-task/internal/repository"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidPageToken = errors.New("service: invalid page token")
+
+// OrderPage is one page of ListOrders. NextPageToken is empty on the last page.
+type OrderPage struct {
+	Orders        []model.Order
+	NextPageToken string
+}
+
+// ListOrders returns a page of orders matching filter, newest first. pageToken is the
+// NextPageToken of the previous page; pageSize is clamped to (0, MaxPageSize]. With
+// withItems the items of the whole page are loaded in one query.
+func (targ *OrderService) ListOrders(ctx context.Context, filter repository.OrderFilter, pageSize int, pageToken string, withItems bool) (OrderPage, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.ListOrders")
+	defer span.End()
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	pageSize = min(pageSize, MaxPageSize)
+	span.SetAttributes(attribute.Int("page.size", pageSize))
+
+	var after *repository.OrderCursor
+	if pageToken != "" {
+		cursor, err := repository.ParseOrderCursor(pageToken)
+		if err != nil {
+			return OrderPage{}, ErrInvalidPageToken
+		}
+		after = &cursor
+	}
+
+	// One extra row tells whether there is a next page.
+	orders, err := targ.repo.ListOrders(ctx, filter, after, pageSize+1)
+	if err != nil {
+		return OrderPage{}, err
+	}
+	var page OrderPage
+	if len(orders) > pageSize {
+		orders = orders[:pageSize]
+		last := orders[len(orders)-1]
+		page.NextPageToken = repository.CursorAt(last).Token()
+	}
+
+	if withItems {
+		uids := make([]string, len(orders))
+		for i, o := range orders {
+			uids[i] = o.OrderUID
+		}
+		items, err := targ.repo.ItemsByOrderUIDs(ctx, uids)
+		if err != nil {
+			return OrderPage{}, err
+		}
+		for i := range orders {
+			orders[i].Items = items[orders[i].OrderUID]
+		}
+	}
+	page.Orders = orders
+	return page, nil
+}
+
+// OrderItems loads the items of several orders at once, keyed by order UID.
+func (targ *OrderService) OrderItems(ctx context.Context, uids []string) (map[string][]model.Item, error) {
+	return targ.repo.ItemsByOrderUIDs(ctx, uids)
+}
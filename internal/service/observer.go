@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"log"
+	"test-task/internal/model"
+)
+
+// OrderObserver is told about order changes after they have been committed. Observers are
+// called synchronously on the caller's goroutine, so they should hand slow work off.
+type OrderObserver interface {
+	OrderCreated(ctx context.Context, order model.Order)
+	OrderStatusChanged(ctx context.Context, order model.Order, change model.StatusChange)
+}
+
+// AddObserver registers o. Observers must be added before the service starts handling orders.
+func (targ *OrderService) AddObserver(o OrderObserver) {
+	targ.observers = append(targ.observers, o)
+}
+
+func (targ *OrderService) notifyCreated(ctx context.Context, order model.Order) {
+	for _, o := range targ.observers {
+		o.OrderCreated(ctx, order)
+	}
+}
+
+func (targ *OrderService) notifyStatusChanged(ctx context.Context, change model.StatusChange) {
+	if len(targ.observers) == 0 {
+		return
+	}
+	order, err := targ.GetOrder(ctx, change.OrderUID)
+	if err != nil {
+		log.Printf("Failed to load order %s for status change observers: %v", change.OrderUID, err)
+		return
+	}
+	for _, o := range targ.observers {
+		o.OrderStatusChanged(ctx, order, change)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"test-task/internal/config"
+	"test-task/internal/metrics"
+	"test-task/internal/model"
+)
+
+const (
+	ActionReject = "reject"
+	ActionWarn   = "warn"
+	ActionIgnore = "ignore"
+)
+
+// Rule is a cross-field business invariant over an order. Check returns one
+// FieldError per violation; Name doubles as the tag put on orders that violate it.
+type Rule interface {
+	Name() string
+	Check(order *model.Order) []FieldError
+}
+
+type ruleEntry struct {
+	rule   Rule
+	action string
+}
+
+type RuleEngine struct {
+	rules []ruleEntry
+}
+
+func NewRuleEngine() *RuleEngine {
+	return &RuleEngine{}
+}
+
+// NewRuleEngineFromConfig registers the built-in payment rules with the actions from config.
+func NewRuleEngineFromConfig() (*RuleEngine, error) {
+	e := NewRuleEngine()
+	for _, rule := range []Rule{
+		AmountTotalRule{Tolerance: config.RuleFor(AmountTotalRuleName).Tolerance},
+		GoodsTotalRule{Tolerance: config.RuleFor(GoodsTotalRuleName).Tolerance},
+		ItemTotalPriceRule{Tolerance: config.RuleFor(ItemTotalPriceRuleName).Tolerance},
+	} {
+		if err := e.Register(rule, config.RuleFor(rule.Name()).Action); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (e *RuleEngine) Register(rule Rule, action string) error {
+	switch action {
+	case ActionReject, ActionWarn, ActionIgnore:
+	default:
+		return fmt.Errorf("rules: unknown action %q for rule %s", action, rule.Name())
+	}
+	e.rules = append(e.rules, ruleEntry{rule: rule, action: action})
+	return nil
+}
+
+// Apply runs every rule against the order. Violations of "warn" rules are logged and
+// the rule name is added to order.Tags; violations of "reject" rules are returned as a
+// *ValidationError.
+func (e *RuleEngine) Apply(order *model.Order) error {
+	var rejected []FieldError
+	for _, entry := range e.rules {
+		if entry.action == ActionIgnore {
+			continue
+		}
+		violations := entry.rule.Check(order)
+		if len(violations) == 0 {
+			continue
+		}
+		metrics.RuleViolations.WithLabelValues(entry.rule.Name(), entry.action).Inc()
+
+		if entry.action == ActionReject {
+			rejected = append(rejected, violations...)
+			continue
+		}
+		msgs := make([]string, 0, len(violations))
+		for _, v := range violations {
+			msgs = append(msgs, v.Field+": "+v.Message)
+		}
+		log.Printf("Order %s violates rule %s: %s", order.OrderUID, entry.rule.Name(), strings.Join(msgs, "; "))
+		order.Tags = append(order.Tags, entry.rule.Name())
+	}
+	if len(rejected) > 0 {
+		return &ValidationError{Fields: rejected}
+	}
+	return nil
+}
+
+const (
+	AmountTotalRuleName    = "amount_total"
+	GoodsTotalRuleName     = "goods_total"
+	ItemTotalPriceRuleName = "item_total_price"
+)
+
+// AmountTotalRule checks amount = goods_total + delivery_cost + custom_fee.
+type AmountTotalRule struct {
+	Tolerance int
+}
+
+func (AmountTotalRule) Name() string { return AmountTotalRuleName }
+
+func (r AmountTotalRule) Check(order *model.Order) []FieldError {
+	p := order.Payment
+	expected := p.GoodsTotal + p.DeliveryCost + p.CustomFee
+	if abs(p.Amount-expected) > r.Tolerance {
+		return []FieldError{{
+			Field:   "payment.amount",
+			Message: fmt.Sprintf("is %d, expected goods_total + delivery_cost + custom_fee = %d", p.Amount, expected),
+		}}
+	}
+	return nil
+}
+
+// GoodsTotalRule checks goods_total = Σ items[].total_price.
+type GoodsTotalRule struct {
+	Tolerance int
+}
+
+func (GoodsTotalRule) Name() string { return GoodsTotalRuleName }
+
+func (r GoodsTotalRule) Check(order *model.Order) []FieldError {
+	sum := 0
+	for _, item := range order.Items {
+		sum += item.TotalPrice
+	}
+	if abs(order.Payment.GoodsTotal-sum) > r.Tolerance {
+		return []FieldError{{
+			Field:   "payment.goods_total",
+			Message: fmt.Sprintf("is %d, expected sum of items total_price = %d", order.Payment.GoodsTotal, sum),
+		}}
+	}
+	return nil
+}
+
+// ItemTotalPriceRule checks total_price ≈ price·(1 − sale/100) for every item.
+type ItemTotalPriceRule struct {
+	Tolerance int
+}
+
+func (ItemTotalPriceRule) Name() string { return ItemTotalPriceRuleName }
+
+func (r ItemTotalPriceRule) Check(order *model.Order) []FieldError {
+	var out []FieldError
+	for i, item := range order.Items {
+		expected := int(math.Round(float64(item.Price) * float64(100-item.Sale) / 100))
+		if abs(item.TotalPrice-expected) > r.Tolerance {
+			out = append(out, FieldError{
+				Field:   fmt.Sprintf("items[%d].total_price", i),
+				Message: fmt.Sprintf("is %d, expected price·(1−sale/100) = %d", item.TotalPrice, expected),
+			})
+		}
+	}
+	return out
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -1,54 +1,129 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"log"
 	"test-task/internal/cache"
 	"test-task/internal/model"
 	"test-task/internal/repository"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+var tracer = otel.Tracer("test-task/internal/service")
+
+var ErrDuplicateOrder = errors.New("service: order already exists")
+
 type OrderService struct {
-	cache *cache.LRU_Cache
-	repo  *repository.OrderRepository
+	cache     *cache.LRU_Cache
+	repo      *repository.OrderRepository
+	rules     *RuleEngine
+	observers []OrderObserver
 }
 
-func NewOrderService(cache *cache.LRU_Cache, repo *repository.OrderRepository) *OrderService {
+func NewOrderService(cache *cache.LRU_Cache, repo *repository.OrderRepository, rules *RuleEngine) *OrderService {
 	return &OrderService{
 		cache: cache,
 		repo:  repo,
+		rules: rules,
 	}
 }
 
-func (targ *OrderService) GetOrder(uid string) (model.Order, error) {
-	order, err := targ.cache.Get(uid)
+func (targ *OrderService) GetOrder(ctx context.Context, uid string) (model.Order, error) {
+	order, _, err := targ.GetOrderWithETag(ctx, uid)
+	return order, err
+}
+
+// GetOrderWithETag is GetOrder that also returns the order's content hash kept with the
+// cache entry.
+func (targ *OrderService) GetOrderWithETag(ctx context.Context, uid string) (model.Order, string, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrder")
+	defer span.End()
+	span.SetAttributes(attribute.String("order.uid", uid))
+
+	order, etag, err := targ.getFromCache(ctx, uid)
 	if err == nil {
 		log.Printf("Order %q found in cache", uid)
-		return order, nil
+		return order, etag, nil
 	}
 
 	if errors.Is(err, cache.ErrNotFound) {
 		log.Printf("Order %q not in cache. Fetching from DB...", uid)
 
-		orderFromDB, dbErr := targ.repo.GetByUID(uid)
+		orderFromDB, dbErr := targ.repo.GetByUID(ctx, uid)
 		if dbErr != nil {
-			return model.Order{}, dbErr
+			span.RecordError(dbErr)
+			span.SetStatus(codes.Error, dbErr.Error())
+			return model.Order{}, "", dbErr
 		}
 
 		log.Printf("Order %q found in DB. Caching...", uid)
-		targ.cache.Set(orderFromDB)
+		etag := targ.cache.Set(orderFromDB)
 
-		return orderFromDB, nil
+		return orderFromDB, etag, nil
 	}
-	return model.Order{}, err
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return model.Order{}, "", err
+}
+
+func (targ *OrderService) getFromCache(ctx context.Context, uid string) (model.Order, string, error) {
+	_, span := tracer.Start(ctx, "cache.Get")
+	defer span.End()
+
+	order, etag, err := targ.cache.GetWithETag(uid)
+	span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+	return order, etag, err
 }
 
-func (targ *OrderService) ProcessNewOrder(order model.Order) {
-	err := targ.repo.SaveOrder(&order)
+// prepareNewOrder validates a new order and applies the business rules to it. Both return
+// a *ValidationError for an order that has to be rejected.
+func (targ *OrderService) prepareNewOrder(order *model.Order) error {
+	if err := ValidateOrder(order); err != nil {
+		return err
+	}
+	if err := targ.rules.Apply(order); err != nil {
+		return err
+	}
+	// Every order starts out created, whatever the input says; later states are only
+	// reached through status changes, which keep the history.
+	order.Status = model.StatusCreated
+	return nil
+}
+
+// ProcessNewOrder validates and stores an order coming from any ingest path (Kafka or HTTP)
+// and caches it. It returns a *ValidationError for invalid orders and ErrDuplicateOrder
+// when the order has already been stored.
+func (targ *OrderService) ProcessNewOrder(ctx context.Context, order model.Order) error {
+	ctx, span := tracer.Start(ctx, "OrderService.ProcessNewOrder")
+	defer span.End()
+	span.SetAttributes(attribute.String("order.uid", order.OrderUID))
+
+	if err := targ.prepareNewOrder(&order); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid order")
+		log.Printf("Rejected order %s: %v", order.OrderUID, err)
+		return err
+	}
+	span.SetAttributes(attribute.StringSlice("order.tags", order.Tags))
+
+	err := targ.repo.SaveOrder(ctx, &order)
+	if errors.Is(err, repository.ErrOrderExists) {
+		span.SetAttributes(attribute.Bool("order.duplicate", true))
+		log.Printf("Order %s already stored, skipping", order.OrderUID)
+		return ErrDuplicateOrder
+	}
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		log.Printf("Failed to save order %s: %v", order.OrderUID, err)
-		return
+		return err
 	}
 	targ.cache.Set(order)
 	log.Printf("Order %s processed and cached", order.OrderUID)
+	targ.notifyCreated(ctx, order)
+	return nil
 }
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"test-task/internal/model"
+	"test-task/internal/repository"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("service: unknown order status")
+	ErrInvalidTransition = errors.New("service: status transition not allowed")
+	ErrStatusUnchanged   = errors.New("service: order already has this status")
+)
+
+const (
+	SourceAPI   = "api"
+	SourceKafka = "kafka"
+)
+
+var transitions = map[string][]string{
+	model.StatusCreated:   {model.StatusPaid, model.StatusCancelled},
+	model.StatusPaid:      {model.StatusShipped, model.StatusCancelled},
+	model.StatusShipped:   {model.StatusDelivered},
+	model.StatusDelivered: {},
+	model.StatusCancelled: {},
+}
+
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// ChangeStatus moves an order to status `to` if the state machine allows it from the
+// current status, records the transition and refreshes the cached order.
+func (targ *OrderService) ChangeStatus(ctx context.Context, uid, to, reason, source string) (model.StatusChange, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.ChangeStatus")
+	defer span.End()
+	span.SetAttributes(attribute.String("order.uid", uid), attribute.String("order.status", to))
+
+	if _, ok := transitions[to]; !ok {
+		return model.StatusChange{}, fmt.Errorf("%w: %q", ErrUnknownStatus, to)
+	}
+
+	from, err := targ.repo.GetStatus(ctx, uid)
+	if err != nil {
+		return model.StatusChange{}, err
+	}
+	if from == to {
+		return model.StatusChange{}, ErrStatusUnchanged
+	}
+	if !CanTransition(from, to) {
+		return model.StatusChange{}, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+
+	change := model.StatusChange{OrderUID: uid, FromStatus: from, ToStatus: to, Reason: reason, Source: source}
+	if err := targ.repo.UpdateStatus(ctx, &change); err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			return model.StatusChange{}, fmt.Errorf("%w: status of order %s changed concurrently", ErrInvalidTransition, uid)
+		}
+		return model.StatusChange{}, err
+	}
+
+	if order, err := targ.cache.Get(uid); err == nil {
+		order.Status = to
+		order.UpdatedAt = change.ChangedAt
+		targ.cache.Set(order)
+	}
+	log.Printf("Order %s status changed %s -> %s (%s)", uid, from, to, source)
+	targ.notifyStatusChanged(ctx, change)
+	return change, nil
+}
+
+func (targ *OrderService) GetStatusHistory(ctx context.Context, uid string) ([]model.StatusChange, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetStatusHistory")
+	defer span.End()
+
+	history, err := targ.repo.GetStatusHistory(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		if _, err := targ.repo.GetStatus(ctx, uid); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
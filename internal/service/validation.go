@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"test-task/internal/model"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return "invalid order: " + strings.Join(parts, "; ")
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// ValidateOrder checks the order against the `validate` tags of the model and
+// reports every failing field by its JSON path, e.g. "delivery.email".
+func ValidateOrder(order *model.Order) error {
+	err := validate.Struct(order)
+	if err == nil {
+		return nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	out := &ValidationError{Fields: make([]FieldError, 0, len(verrs))}
+	for _, fe := range verrs {
+		_, path, _ := strings.Cut(fe.Namespace(), ".")
+		out.Fields = append(out.Fields, FieldError{Field: path, Message: describe(fe)})
+	}
+	return out
+}
+
+func describe(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email"
+	case "gt", "gte", "lt", "lte":
+		ops := map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+		return fmt.Sprintf("must be %s %s", ops[fe.Tag()], fe.Param())
+	default:
+		return fmt.Sprintf("failed %q validation", fe.Tag())
+	}
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"test-task/internal/config"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporterName := strings.ToLower(config.TracingExporter())
+	if exporterName == "" || exporterName == "none" {
+		log.Println("Tracing disabled")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closer, err := newExporter(ctx, exporterName)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(config.TracingServiceName()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: failed to build resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TracingSampleRatio()))),
+	)
+	otel.SetTracerProvider(tp)
+	log.Printf("Tracing enabled with %s exporter", exporterName)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			if cErr := closer.Close(); cErr != nil && err == nil {
+				err = cErr
+			}
+		}
+		return err
+	}, nil
+}
+
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, io.Closer, error) {
+	switch name {
+	case "otlp":
+		endpoint := config.TracingEndpoint()
+		if strings.ToLower(config.TracingProtocol()) == "http" {
+			opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+			if config.TracingInsecure() {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+			exp, err := otlptracehttp.New(ctx, opts...)
+			if err != nil {
+				return nil, nil, fmt.Errorf("tracing: failed to create otlp http exporter: %w", err)
+			}
+			return exp, nil, nil
+		}
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+		if config.TracingInsecure() {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exp, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tracing: failed to create otlp grpc exporter: %w", err)
+		}
+		return exp, nil, nil
+	case "stdout":
+		exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, nil, fmt.Errorf("tracing: failed to create stdout exporter: %w", err)
+		}
+		return exp, nil, nil
+	case "file":
+		path := config.TracingFile()
+		if path == "" {
+			return nil, nil, fmt.Errorf("tracing: file exporter requires a file path")
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tracing: failed to open %s: %w", path, err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("tracing: failed to create file exporter: %w", err)
+		}
+		return exp, f, nil
+	default:
+		return nil, nil, fmt.Errorf("tracing: unknown exporter %q", name)
+	}
+}
+
+// HeaderCarrier adapts kafka message headers to the OpenTelemetry propagation API.
+type HeaderCarrier struct {
+	headers *[]kafka.Header
+}
+
+func NewHeaderCarrier(headers *[]kafka.Header) HeaderCarrier {
+	return HeaderCarrier{headers: headers}
+}
+
+func (c HeaderCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if strings.EqualFold(h.Key, key) {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// RouteSpanName renames the server span created by otelhttp to the matched chi route,
+// which is only known after routing.
+func RouteSpanName(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				trace.SpanFromContext(r.Context()).SetName(r.Method + " " + pattern)
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"test-task/internal/masking"
+	"test-task/internal/model"
+	"test-task/internal/repository"
+	"time"
+)
+
+// EventTypes lists the events a subscription can ask for.
+var EventTypes = []string{model.EventOrderCreated, model.EventOrderStatusChanged}
+
+// Event is the JSON body of a webhook request. Order is masked like an API response to a
+// caller without the PII scope.
+type Event struct {
+	Type         string              `json:"type"`
+	OccurredAt   time.Time           `json:"occurred_at"`
+	Order        model.Order         `json:"order"`
+	StatusChange *model.StatusChange `json:"status_change,omitempty"`
+}
+
+// Dispatcher turns order events into pending deliveries for every matching subscription.
+// It implements repository.TxHook, so the deliveries are enqueued in the transaction that
+// stores the order change and exist exactly when the change does; a Sender sends them.
+type Dispatcher struct {
+	repo   *repository.WebhookRepository
+	masker *masking.Masker
+}
+
+func NewDispatcher(repo *repository.WebhookRepository, masker *masking.Masker) *Dispatcher {
+	return &Dispatcher{repo: repo, masker: masker}
+}
+
+func (d *Dispatcher) OrderCreatedTx(ctx context.Context, tx *sql.Tx, order model.Order) error {
+	return d.enqueue(ctx, tx, Event{Type: model.EventOrderCreated, OccurredAt: time.Now().UTC(), Order: order})
+}
+
+func (d *Dispatcher) OrderStatusChangedTx(ctx context.Context, tx *sql.Tx, order model.Order, change model.StatusChange) error {
+	return d.enqueue(ctx, tx, Event{Type: model.EventOrderStatusChanged, OccurredAt: change.ChangedAt, Order: order, StatusChange: &change})
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, tx *sql.Tx, event Event) error {
+	fields := event.Order.FilterValues()
+	event.Order = d.masker.MaskOrder(event.Order)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s webhook for order %s: %w", event.Type, event.Order.OrderUID, err)
+	}
+	return d.repo.EnqueueTx(ctx, tx, event.Type, event.Order.OrderUID, fields, payload)
+}
+
+// ValidateSubscription checks event types and filter fields of a new subscription.
+func ValidateSubscription(sub repository.WebhookSubscription) error {
+	if len(sub.EventTypes) == 0 {
+		return fmt.Errorf("at least one event type is required, one of %v", EventTypes)
+	}
+	for _, t := range sub.EventTypes {
+		if !slices.Contains(EventTypes, t) {
+			return fmt.Errorf("unknown event type %q, use one of %v", t, EventTypes)
+		}
+	}
+	for field := range sub.Filters {
+		if !model.IsFilterField(field) {
+			return fmt.Errorf("cannot filter on %q", field)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"test-task/internal/config"
+	"test-task/internal/repository"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Sign returns the signature header value for body sent at ts:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>" keyed with secret>".
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliveryQueue is the part of repository.WebhookRepository the sender works with.
+type deliveryQueue interface {
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]repository.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id int64, status string, statusCode int, attemptErr string, next time.Time) error
+}
+
+// Sender delivers pending webhooks from the delivery queue. A 2xx response marks a delivery
+// as delivered; anything else is retried with exponential backoff until maxAttempts.
+type Sender struct {
+	repo   deliveryQueue
+	client *http.Client
+	conf   config.WebhookConf
+}
+
+func NewSender(repo *repository.WebhookRepository) *Sender {
+	return newSender(repo, config.WebhookConfig())
+}
+
+func newSender(repo deliveryQueue, conf config.WebhookConf) *Sender {
+	return &Sender{
+		repo: repo,
+		client: &http.Client{
+			Timeout: time.Duration(conf.TimeoutMs) * time.Millisecond,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		conf: conf,
+	}
+}
+
+// Run sends due deliveries until ctx is cancelled.
+func (s *Sender) Run(ctx context.Context) {
+	poll := time.Duration(s.conf.PollMs) * time.Millisecond
+	lease := 2 * s.client.Timeout
+	for {
+		deliveries, err := s.repo.ClaimDue(ctx, s.conf.Concurrency*10, lease)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to claim webhook deliveries: %v", err)
+		}
+
+		s.sendAll(ctx, deliveries)
+
+		if len(deliveries) == 0 {
+			select {
+			case <-ctx.Done():
+				log.Println("Stopping webhook sender...")
+				return
+			case <-time.After(poll):
+			}
+		}
+	}
+}
+
+func (s *Sender) sendAll(ctx context.Context, deliveries []repository.WebhookDelivery) {
+	sem := make(chan struct{}, s.conf.Concurrency)
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			s.deliver(ctx, d)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Sender) deliver(ctx context.Context, d repository.WebhookDelivery) {
+	statusCode, err := s.send(ctx, d)
+	if ctx.Err() != nil {
+		// Shutting down: the lease expires and the delivery is retried after restart.
+		return
+	}
+
+	status := repository.DeliveryDelivered
+	var next time.Time
+	var errText string
+	if err != nil {
+		errText = err.Error()
+		status = repository.DeliveryFailed
+		if attempt := d.Attempts + 1; attempt < s.conf.MaxAttempts {
+			status = repository.DeliveryPending
+			next = time.Now().Add(s.backoff(attempt))
+		}
+		log.Printf("Webhook delivery %d to %s failed (attempt %d, %s): %v", d.ID, d.URL, d.Attempts+1, status, err)
+	}
+
+	if err := s.repo.RecordAttempt(context.WithoutCancel(ctx), d.ID, status, statusCode, errText, next); err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+func (s *Sender) send(ctx context.Context, d repository.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "order-service-webhooks")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(d.Secret, time.Now(), d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the pause before attempt+1: backoffMin doubled per attempt, capped at backoffMax.
+func (s *Sender) backoff(attempt int) time.Duration {
+	d := time.Duration(s.conf.BackoffMinMs) * time.Millisecond
+	maxDelay := time.Duration(s.conf.BackoffMaxMs) * time.Millisecond
+	for i := 1; i < attempt && d < maxDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxDelay)
+}
@@ -0,0 +1,328 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task/internal/config"
+	"test-task/internal/repository"
+)
+
+// attempt is one RecordAttempt call.
+type attempt struct {
+	id         int64
+	status     string
+	statusCode int
+	err        string
+	next       time.Time
+}
+
+// fakeQueue hands out its deliveries once and records the outcome of every attempt.
+type fakeQueue struct {
+	mtx        sync.Mutex
+	deliveries []repository.WebhookDelivery
+	attempts   []attempt
+}
+
+func (q *fakeQueue) ClaimDue(_ context.Context, limit int, _ time.Duration) ([]repository.WebhookDelivery, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	n := min(limit, len(q.deliveries))
+	claimed := q.deliveries[:n]
+	q.deliveries = q.deliveries[n:]
+	return claimed, nil
+}
+
+func (q *fakeQueue) RecordAttempt(_ context.Context, id int64, status string, statusCode int, attemptErr string, next time.Time) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.attempts = append(q.attempts, attempt{id, status, statusCode, attemptErr, next})
+	return nil
+}
+
+func (q *fakeQueue) recorded() []attempt {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return append([]attempt(nil), q.attempts...)
+}
+
+// receiver answers with the statuses in turn, repeating the last one, and keeps the requests.
+type receiver struct {
+	*httptest.Server
+	mtx      sync.Mutex
+	statuses []int
+	delay    time.Duration
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rcv := &receiver{statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mtx.Lock()
+		rcv.requests = append(rcv.requests, r)
+		rcv.bodies = append(rcv.bodies, body)
+		status := rcv.statuses[min(len(rcv.requests), len(rcv.statuses))-1]
+		delay := rcv.delay
+		rcv.mtx.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) request(i int) (*http.Request, []byte) {
+	rcv.mtx.Lock()
+	defer rcv.mtx.Unlock()
+	return rcv.requests[i], rcv.bodies[i]
+}
+
+func (rcv *receiver) received() int {
+	rcv.mtx.Lock()
+	defer rcv.mtx.Unlock()
+	return len(rcv.requests)
+}
+
+var testConf = config.WebhookConf{
+	Enabled:      true,
+	TimeoutMs:    1000,
+	MaxAttempts:  3,
+	BackoffMinMs: 1000,
+	BackoffMaxMs: 3000,
+	PollMs:       10,
+	Concurrency:  2,
+}
+
+func testDelivery(url string) repository.WebhookDelivery {
+	return repository.WebhookDelivery{
+		ID:        17,
+		EventType: "order.created",
+		OrderUID:  "b563feb7b2b84b6test",
+		Payload:   []byte(`{"type":"order.created","order":{"order_uid":"b563feb7b2b84b6test"}}`),
+		Status:    repository.DeliveryPending,
+		URL:       url,
+		Secret:    "s3cret",
+	}
+}
+
+// verify checks a signature header the way a receiver should.
+func verify(t *testing.T, secret, header string, body []byte) {
+	t.Helper()
+	ts, mac, ok := strings.Cut(header, ",")
+	if !ok || !strings.HasPrefix(ts, "t=") || !strings.HasPrefix(mac, "v1=") {
+		t.Fatalf("malformed signature header %q", header)
+	}
+	unix, err := strconv.ParseInt(strings.TrimPrefix(ts, "t="), 10, 64)
+	if err != nil {
+		t.Fatalf("malformed timestamp in %q", header)
+	}
+	if age := time.Since(time.Unix(unix, 0)); age < -time.Minute || age > time.Minute {
+		t.Fatalf("signature timestamp is %s off", age)
+	}
+	if want := Sign(secret, time.Unix(unix, 0), body); !hmac.Equal([]byte(header), []byte(want)) {
+		t.Fatalf("signature = %q, want %q", header, want)
+	}
+}
+
+func TestSign(t *testing.T) {
+	got := Sign("s3cret", time.Unix(1700000000, 0), []byte("{}"))
+	if !strings.HasPrefix(got, "t=1700000000,v1=") || len(got) != len("t=1700000000,v1=")+64 {
+		t.Fatalf("Sign = %q", got)
+	}
+	if got == Sign("other", time.Unix(1700000000, 0), []byte("{}")) {
+		t.Fatal("signature does not depend on the secret")
+	}
+	if got == Sign("s3cret", time.Unix(1700000001, 0), []byte("{}")) {
+		t.Fatal("signature does not depend on the timestamp")
+	}
+}
+
+func TestDeliverSignsRequest(t *testing.T) {
+	rcv := newReceiver(t, http.StatusNoContent)
+	queue := &fakeQueue{}
+	d := testDelivery(rcv.URL)
+
+	newSender(queue, testConf).deliver(context.Background(), d)
+
+	if rcv.received() != 1 {
+		t.Fatalf("receiver got %d requests, want 1", rcv.received())
+	}
+	r, body := rcv.request(0)
+	if string(body) != string(d.Payload) {
+		t.Fatalf("body = %s, want %s", body, d.Payload)
+	}
+	if r.Header.Get(EventHeader) != "order.created" || r.Header.Get(DeliveryHeader) != "17" {
+		t.Fatalf("headers = %v", r.Header)
+	}
+	if r.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("Content-Type = %q", r.Header.Get("Content-Type"))
+	}
+	verify(t, d.Secret, r.Header.Get(SignatureHeader), body)
+
+	got := queue.recorded()
+	if len(got) != 1 || got[0].status != repository.DeliveryDelivered || got[0].statusCode != http.StatusNoContent || !got[0].next.IsZero() {
+		t.Fatalf("attempts = %+v, want one delivered", got)
+	}
+}
+
+func TestDeliverRetriesServerErrors(t *testing.T) {
+	rcv := newReceiver(t, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK)
+	queue := &fakeQueue{}
+	s := newSender(queue, testConf)
+	d := testDelivery(rcv.URL)
+
+	wantBackoff := []time.Duration{time.Second, 2 * time.Second}
+	for i, want := range wantBackoff {
+		d.Attempts = i
+		before := time.Now()
+		s.deliver(context.Background(), d)
+
+		a := queue.recorded()[i]
+		if a.status != repository.DeliveryPending || a.err == "" {
+			t.Fatalf("attempt %d = %+v, want pending with an error", i+1, a)
+		}
+		if a.statusCode != rcv.statuses[i] {
+			t.Fatalf("attempt %d status code = %d, want %d", i+1, a.statusCode, rcv.statuses[i])
+		}
+		if wait := a.next.Sub(before); wait < want || wait > want+time.Second {
+			t.Fatalf("attempt %d retries in %s, want %s", i+1, wait, want)
+		}
+	}
+
+	d.Attempts = 2
+	s.deliver(context.Background(), d)
+	if a := queue.recorded()[2]; a.status != repository.DeliveryDelivered || a.statusCode != http.StatusOK {
+		t.Fatalf("third attempt = %+v, want delivered", a)
+	}
+	if rcv.received() != 3 {
+		t.Fatalf("receiver got %d requests, want 3", rcv.received())
+	}
+}
+
+func TestDeliverGivesUpAfterMaxAttempts(t *testing.T) {
+	rcv := newReceiver(t, http.StatusBadGateway)
+	queue := &fakeQueue{}
+	d := testDelivery(rcv.URL)
+	d.Attempts = testConf.MaxAttempts - 1
+
+	newSender(queue, testConf).deliver(context.Background(), d)
+
+	got := queue.recorded()
+	if len(got) != 1 || got[0].status != repository.DeliveryFailed || !got[0].next.IsZero() || got[0].statusCode != http.StatusBadGateway {
+		t.Fatalf("attempts = %+v, want one failed with no next attempt", got)
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	target := newReceiver(t, http.StatusOK)
+	rcv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	t.Cleanup(rcv.Close)
+	queue := &fakeQueue{}
+
+	newSender(queue, testConf).deliver(context.Background(), testDelivery(rcv.URL))
+
+	if target.received() != 0 {
+		t.Fatal("redirect was followed")
+	}
+	if a := queue.recorded()[0]; a.status != repository.DeliveryPending || a.statusCode != http.StatusFound {
+		t.Fatalf("attempt = %+v, want pending after a 302", a)
+	}
+}
+
+func TestDeliverTimeout(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	rcv.delay = time.Second
+	conf := testConf
+	conf.TimeoutMs = 50
+	queue := &fakeQueue{}
+
+	start := time.Now()
+	newSender(queue, conf).deliver(context.Background(), testDelivery(rcv.URL))
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("deliver took %s with a 50ms timeout", elapsed)
+	}
+
+	a := queue.recorded()[0]
+	if a.status != repository.DeliveryPending || a.statusCode != 0 || !strings.Contains(a.err, "Timeout") {
+		t.Fatalf("attempt = %+v, want pending after a timeout", a)
+	}
+}
+
+func TestDeliverShutdownLeavesDeliveryToLease(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	rcv.delay = time.Second
+	queue := &fakeQueue{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	newSender(queue, testConf).deliver(ctx, testDelivery(rcv.URL))
+
+	if got := queue.recorded(); len(got) != 0 {
+		t.Fatalf("attempts = %+v, want none recorded on shutdown", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	conf := testConf
+	conf.BackoffMinMs, conf.BackoffMaxMs = 1000, 60000
+	s := newSender(&fakeQueue{}, conf)
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute}
+	for i, w := range want {
+		if got := s.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+	if got := s.backoff(1000); got != time.Minute {
+		t.Errorf("backoff(1000) = %s, want the cap", got)
+	}
+}
+
+func TestRunSendsClaimedDeliveries(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	queue := &fakeQueue{}
+	for i := range 5 {
+		d := testDelivery(rcv.URL)
+		d.ID = int64(i + 1)
+		queue.deliveries = append(queue.deliveries, d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		newSender(queue, testConf).Run(ctx)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for len(queue.recorded()) < 5 {
+		select {
+		case <-deadline:
+			t.Fatalf("recorded %d of 5 deliveries", len(queue.recorded()))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	for _, a := range queue.recorded() {
+		if a.status != repository.DeliveryDelivered {
+			t.Fatalf("attempt = %+v, want delivered", a)
+		}
+	}
+}
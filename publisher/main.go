@@ -6,24 +6,45 @@ import (
 	"fmt"
 	"log"
 	"test-task/internal/config"
+	"test-task/internal/decoder"
+	"test-task/internal/kafkaconn"
 	"test-task/internal/model"
+	"test-task/internal/orderpb"
+	"test-task/internal/tracing"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // topic, broker are configurable via env in config package
 
 func main() {
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Fatalf("Error init tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Error shutdown tracing: %v", err)
+		}
+	}()
+	tracer := otel.Tracer("test-task/publisher")
+
 	cntFakeData := config.PublisherCount()
 	topic := config.PublisherTopic()
-	broker := config.PublisherBroker()
+	format := decoder.FormatOf(config.PublisherFormat())
+	if format != decoder.FormatJSON && format != decoder.FormatProtobuf {
+		log.Fatalf("Unsupported publisher format %q, use json or protobuf", config.PublisherFormat())
+	}
 
-	w := &kafka.Writer{
-		Addr:     kafka.TCP(broker),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+	conn, err := kafkaconn.New(config.PublisherBrokers(), config.PublisherSecurity())
+	if err != nil {
+		log.Fatalf("Failed to configure kafka connection: %v", err)
 	}
+	w := conn.Writer(topic)
 
 	for i := 0; i < cntFakeData; i++ {
 		var fake model.Order
@@ -32,21 +53,31 @@ func main() {
 			log.Fatal(errF)
 		}
 
-		data, err := json.Marshal(&fake)
+		data, err := encode(format, fake)
 		if err != nil {
-			log.Fatalf("Failed to marshal fake order to JSON: %v", err)
-		}
-
-		if !json.Valid(data) {
-			log.Fatalf("model.json contains invalid JSON")
+			log.Fatalf("Failed to encode fake order as %s: %v", format, err)
 		}
 		fmt.Println(fake.OrderUID)
 
-		err = w.WriteMessages(context.Background(),
-			kafka.Message{
-				Value: data,
-			},
+		ctx, span := tracer.Start(context.Background(), topic+" publish",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				attribute.String("messaging.system", "kafka"),
+				attribute.String("messaging.destination.name", topic),
+				attribute.String("order.uid", fake.OrderUID),
+			),
 		)
+		msg := kafka.Message{
+			Value: data,
+			Headers: []kafka.Header{
+				{Key: decoder.SchemaVersionHeader, Value: []byte(decoder.CurrentSchemaVersion)},
+				{Key: decoder.ContentTypeHeader, Value: []byte(format)},
+			},
+		}
+		otel.GetTextMapPropagator().Inject(ctx, tracing.NewHeaderCarrier(&msg.Headers))
+
+		err = w.WriteMessages(ctx, msg)
+		span.End()
 		if err != nil {
 			log.Fatalf("failed to write messages: %v", err)
 		}
@@ -56,3 +87,17 @@ func main() {
 
 	log.Println("Message published to Kafka successfully!")
 }
+
+func encode(format string, order model.Order) ([]byte, error) {
+	if format == decoder.FormatProtobuf {
+		return orderpb.Marshal(order)
+	}
+	data, err := json.Marshal(&order)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("marshalled order is not valid JSON")
+	}
+	return data, nil
+}
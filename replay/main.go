@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"test-task/internal/cache"
+	"test-task/internal/config"
+	"test-task/internal/db"
+	"test-task/internal/decoder"
+	"test-task/internal/kafka"
+	"test-task/internal/kafkaconn"
+	"test-task/internal/repository"
+	"test-task/internal/schemaregistry"
+	"test-task/internal/service"
+	"time"
+)
+
+// replay re-reads a window of the orders topic into the database, e.g.
+//
+//	go run ./replay -partition 0 -from-time 2024-05-01T00:00:00Z -to-time 2024-05-02T00:00:00Z
+func main() {
+	partition := flag.Int("partition", 0, "partition of the orders topic")
+	fromOffset := flag.Int64("from-offset", -1, "first offset to replay")
+	fromTime := flag.String("from-time", "", "replay from the first message at or after this RFC 3339 time")
+	toOffset := flag.Int64("to-offset", -1, "last offset to replay (inclusive)")
+	toTime := flag.String("to-time", "", "stop before the first message at or after this RFC 3339 time")
+	every := flag.Duration("progress", 5*time.Second, "progress report interval")
+	flag.Parse()
+
+	req := kafka.ReplayRequest{Partition: *partition}
+	if *fromOffset >= 0 {
+		req.FromOffset = fromOffset
+	}
+	if *toOffset >= 0 {
+		req.ToOffset = toOffset
+	}
+	req.FromTime = parseTime("from-time", *fromTime)
+	req.ToTime = parseTime("to-time", *toTime)
+
+	database := db.InitDB()
+	defer database.Close()
+
+	rules, err := service.NewRuleEngineFromConfig()
+	if err != nil {
+		log.Fatalf("Error init business rules: %v", err)
+	}
+	dbBreaker := db.NewBreaker(database)
+	defer dbBreaker.Close()
+	orderService := service.NewOrderService(cache.InitCache(database), repository.NewOrderRepository(database, dbBreaker), rules)
+
+	decoders := decoder.Default()
+	if url := config.SchemaRegistryURL(); url != "" {
+		registry := schemaregistry.NewClient(url, config.SchemaRegistryUsername(), config.SchemaRegistryPassword())
+		decoders.Register(decoder.FormatAvro, decoder.CurrentSchemaVersion, decoder.NewAvroDecoder(registry).Decode)
+	}
+
+	kafkaConn, err := kafkaconn.FromConfig()
+	if err != nil {
+		log.Fatalf("Error init kafka connection: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	replayer := kafka.NewReplayer(kafkaConn, orderService, decoders, dbBreaker)
+	defer replayer.Close()
+
+	progress, err := replayer.Start(ctx, req)
+	if err != nil {
+		log.Fatalf("Failed to start replay: %v", err)
+	}
+
+	report := time.NewTicker(*every)
+	defer report.Stop()
+	poll := time.NewTicker(100 * time.Millisecond)
+	defer poll.Stop()
+	interrupted := ctx.Done()
+	for progress.State == kafka.ReplayRunning {
+		select {
+		case <-interrupted:
+			replayer.Cancel(progress.ID)
+			interrupted = nil
+		case <-report.C:
+			log.Printf("offset %d of [%d, %d): %d processed, %d created, %d duplicate",
+				progress.Offset, progress.StartOffset, progress.EndOffset, progress.Processed, progress.Created, progress.Duplicate)
+		case <-poll.C:
+		}
+		progress, _ = replayer.Get(progress.ID)
+	}
+
+	if progress.State != kafka.ReplayCompleted {
+		os.Exit(1)
+	}
+}
+
+func parseTime(name, value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Fatalf("Invalid -%s: %v", name, err)
+	}
+	return &t
+}
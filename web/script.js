@@ -1,8 +1,14 @@
 document.addEventListener('DOMContentLoaded', () => {
     const fetchBtn = document.getElementById('fetchOrderBtn');
     const orderIdInput = document.getElementById('orderIdInput');
+    const apiKeyInput = document.getElementById('apiKeyInput');
     const resultDiv = document.getElementById('result');
 
+    apiKeyInput.value = localStorage.getItem('apiKey') || '';
+    apiKeyInput.addEventListener('change', () => {
+        localStorage.setItem('apiKey', apiKeyInput.value.trim());
+    });
+
     fetchBtn.addEventListener('click', fetchOrder);
     orderIdInput.addEventListener('keypress', (event) => {
         if (event.key === 'Enter') {
@@ -21,7 +27,17 @@ document.addEventListener('DOMContentLoaded', () => {
 
         try {
             
-            const response = await fetch(`/order/${orderId}`);
+            const headers = {};
+            const apiKey = apiKeyInput.value.trim();
+            if (apiKey) {
+                headers['X-API-Key'] = apiKey;
+            }
+            const response = await fetch(`/api/v1/orders/${encodeURIComponent(orderId)}`, { headers });
+
+            if (response.status === 401 || response.status === 403) {
+                 resultDiv.innerHTML = '<p class="error">Нет доступа. Проверьте API-ключ.</p>';
+                 return;
+            }
 
             if (response.status === 404) {
                  resultDiv.innerHTML = `<p class="error">Заказ с ID <strong>${orderId}</strong> не найден.</p>`;